@@ -1,33 +1,37 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"time"
 )
 
 const countdownStart = 3
+const countdownInterval = 1 * time.Second
 const finalWord = "Go!"
 
-type Sleeper interface {
-	Sleep()
-}
-
-type DefaultSleeper struct{}
-
-func (d *DefaultSleeper) Sleep() {
-	time.Sleep(1 * time.Second)
-}
-
-func Countdown(w io.Writer, sleeper Sleeper) {
+// Countdown counts down to finalWord, sleeping between the numbers. If ctx
+// is cancelled it stops without writing finalWord and returns ctx.Err().
+func Countdown(ctx context.Context, w io.Writer, sleeper ContextSleeper) error {
 	for i := countdownStart; i > 0; i-- {
 		fmt.Fprintln(w, i)
-		sleeper.Sleep()
+		if err := sleeper.Sleep(ctx, countdownInterval); err != nil {
+			return err
+		}
 	}
 	fmt.Fprint(w, finalWord)
+	return nil
 }
 
 func main() {
-	Countdown(os.Stdout, &DefaultSleeper{})
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := Countdown(ctx, os.Stdout, &DefaultSleeper{})
+	stop()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "countdown stopped:", err)
+		os.Exit(1)
+	}
 }
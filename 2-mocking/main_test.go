@@ -2,7 +2,11 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestCountdown(t *testing.T) {
@@ -10,8 +14,9 @@ func TestCountdown(t *testing.T) {
 		buffer := &bytes.Buffer{}
 		spySleeper := &SpySleeper{}
 
-		Countdown(buffer, spySleeper)
+		err := Countdown(context.Background(), buffer, spySleeper)
 
+		assertNoError(t, err)
 		got := buffer.String()
 		want := `3
 2
@@ -24,6 +29,49 @@ Go!`
 		}
 
 	})
+
+	t.Run("sleeps a second between the numbers", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		sleeper := &FakeSleeper{}
+
+		done := make(chan error, 1)
+		go func() { done <- Countdown(context.Background(), buffer, sleeper) }()
+		for i := 0; i < countdownStart; i++ {
+			assertNoError(t, sleeper.WaitForSleepers(1, time.Second))
+			sleeper.Advance(time.Second)
+		}
+
+		assertNoError(t, <-done)
+		want := []time.Duration{time.Second, time.Second, time.Second}
+		if got := sleeper.Durations(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got sleeps %v want %v", got, want)
+		}
+	})
+
+	t.Run("stops when cancelled", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		sleeper := &FakeSleeper{}
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan error, 1)
+		go func() { done <- Countdown(ctx, buffer, sleeper) }()
+		assertNoError(t, sleeper.WaitForSleepers(1, time.Second))
+		sleeper.Advance(time.Second)
+		assertNoError(t, sleeper.WaitForSleepers(1, time.Second))
+		cancel()
+
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v want %v", err, context.Canceled)
+		}
+		assertCorrectMessage(t, buffer.String(), "3\n2\n")
+	})
+}
+
+func assertNoError(t testing.TB, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("didn't expect an error but got one, %v", err)
+	}
 }
 
 func assertCorrectMessage(t testing.TB, got string, want string) {
@@ -38,6 +86,7 @@ type SpySleeper struct {
 	Calls int
 }
 
-func (s *SpySleeper) Sleep() {
+func (s *SpySleeper) Sleep(ctx context.Context, _ time.Duration) error {
 	s.Calls++
+	return ctx.Err()
 }
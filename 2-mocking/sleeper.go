@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ContextSleeper sleeps for d, or returns ctx.Err() as soon as ctx is
+// cancelled.
+type ContextSleeper interface {
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+type DefaultSleeper struct{}
+
+func (d *DefaultSleeper) Sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// FakeSleeper is a ContextSleeper for tests. It only wakes its sleepers
+// when it is advanced, and remembers how long each of them asked to sleep.
+type FakeSleeper struct {
+	mu        sync.Mutex
+	now       time.Duration
+	durations []time.Duration
+	sleepers  []fakeSleep
+}
+
+type fakeSleep struct {
+	until time.Duration
+	wake  chan struct{}
+}
+
+func (f *FakeSleeper) Sleep(ctx context.Context, d time.Duration) error {
+	f.mu.Lock()
+	f.durations = append(f.durations, d)
+	if d <= 0 {
+		f.mu.Unlock()
+		return ctx.Err()
+	}
+	s := fakeSleep{f.now + d, make(chan struct{})}
+	f.sleepers = append(f.sleepers, s)
+	f.mu.Unlock()
+
+	select {
+	case <-s.wake:
+		return nil
+	case <-ctx.Done():
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for i, other := range f.sleepers {
+			if other.wake == s.wake {
+				f.sleepers = append(f.sleepers[:i], f.sleepers[i+1:]...)
+				break
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// Advance moves the time on, waking any sleeper whose time has come.
+func (f *FakeSleeper) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now += d
+
+	sleeping := f.sleepers[:0]
+	for _, s := range f.sleepers {
+		if s.until > f.now {
+			sleeping = append(sleeping, s)
+			continue
+		}
+		close(s.wake)
+	}
+	f.sleepers = sleeping
+}
+
+// Sleeping is how many calls to Sleep are still waiting.
+func (f *FakeSleeper) Sleeping() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.sleepers)
+}
+
+// WaitForSleepers waits up to timeout for exactly n calls to Sleep to be
+// waiting, so a test knows the code under it has got that far.
+func (f *FakeSleeper) WaitForSleepers(n int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for f.Sleeping() != n {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("got %d sleepers want %d", f.Sleeping(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return nil
+}
+
+// Durations is every duration Sleep was asked for, in order.
+func (f *FakeSleeper) Durations() []time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]time.Duration(nil), f.durations...)
+}
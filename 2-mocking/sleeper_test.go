@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSleepers(t *testing.T) {
+	sleepers := map[string]func() ContextSleeper{
+		"default": func() ContextSleeper { return &DefaultSleeper{} },
+		"fake":    func() ContextSleeper { return &FakeSleeper{} },
+	}
+
+	for name, makeSleeper := range sleepers {
+		t.Run(name, func(t *testing.T) {
+			t.Run("wakes up when cancelled", func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				start := time.Now()
+				err := makeSleeper().Sleep(ctx, time.Hour)
+
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("got error %v want %v", err, context.Canceled)
+				}
+				if took := time.Since(start); took > time.Second {
+					t.Errorf("took %v to wake up", took)
+				}
+			})
+
+			t.Run("doesn't wait for no time", func(t *testing.T) {
+				assertNoError(t, makeSleeper().Sleep(context.Background(), 0))
+			})
+		})
+	}
+
+	t.Run("default sleeps", func(t *testing.T) {
+		assertNoError(t, (&DefaultSleeper{}).Sleep(context.Background(), time.Millisecond))
+	})
+
+	t.Run("fake sleeps until it is advanced far enough", func(t *testing.T) {
+		sleeper := &FakeSleeper{}
+		done := make(chan error, 1)
+		go func() { done <- sleeper.Sleep(context.Background(), time.Second) }()
+
+		assertNoError(t, sleeper.WaitForSleepers(1, time.Second))
+		sleeper.Advance(time.Second / 2)
+		if sleeper.Sleeping() != 1 {
+			t.Fatal("woke up too early")
+		}
+		sleeper.Advance(time.Second / 2)
+
+		assertNoError(t, <-done)
+		if got, want := sleeper.Durations(), []time.Duration{time.Second}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got sleeps %v want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	concurrency "oop-lectures/3-concurrency"
+	"oop-lectures/3-concurrency/report"
+)
+
+// checksites reads urls, one a line, from the file named on the command
+// line or from stdin, checks them and writes a report to stdout.
+//
+//	checksites -format html urls.txt > report.html
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("checksites", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", string(report.JSON), "report format: json, csv or html")
+	timeout := flags.Duration("timeout", concurrency.DefaultCheckTimeout, "time limit for each request")
+	retries := flags.Int("retries", 2, "how many times to retry a failing site")
+	limit := flags.Int("concurrency", 10, "how many sites to check at once, 0 for no limit")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: checksites [flags] [file]")
+		fmt.Fprintln(stderr, "Reads urls from file, or stdin if there is none or it is -.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	reportFormat, err := report.ParseFormat(*format)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	urls, err := readURLs(flags.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	checker := &concurrency.HTTPChecker{Timeout: *timeout, Retries: *retries}
+	results, err := concurrency.CheckWebsitesDetailed(ctx, checker.Check, urls, *limit)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if err := newReport(results, time.Now()).Write(stdout, reportFormat); err != nil {
+		fmt.Fprintf(stderr, "problem writing report, %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// readURLs reads one url a line from the file at path, or from stdin if path
+// is empty or "-". Blank lines and lines starting with # are skipped.
+func readURLs(path string, stdin io.Reader) ([]string, error) {
+	in := stdin
+	if path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("problem opening %s, %v", path, err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	var urls []string
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("problem reading urls, %v", err)
+	}
+	return urls, nil
+}
+
+// newReport has one row per url checked, so urls that were asked for in
+// more than one form are only reported once.
+func newReport(results map[string]concurrency.CheckResult, generated time.Time) report.Report {
+	var rows []report.Result
+	reported := map[string]bool{}
+	for _, res := range results {
+		if reported[res.URL] {
+			continue
+		}
+		reported[res.URL] = true
+		row := report.Result{
+			URL:        res.URL,
+			Up:         res.Up,
+			StatusCode: res.StatusCode,
+			Latency:    res.Latency,
+			FinalURL:   res.FinalURL,
+			ErrorKind:  string(res.ErrorKind),
+		}
+		if res.Err != nil {
+			row.Error = res.Err.Error()
+		}
+		rows = append(rows, row)
+	}
+	return report.New(rows, generated)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	defer down.Close()
+
+	urls := "# sites to check\n" + up.URL + "\n\n" + down.URL + "\nwaat://furhurterwe.geds\n" + up.URL + "/\n"
+
+	t.Run("reads urls from stdin and writes a CSV report", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+
+		code := run(context.Background(), []string{"-format", "csv", "-retries", "0"}, strings.NewReader(urls), &stdout, &stderr)
+
+		assertExitCode(t, code, 0, &stderr)
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		if len(lines) != 4 {
+			t.Fatalf("got %d lines want a header and 3 results, %q", len(lines), stdout.String())
+		}
+		rows := strings.Join(lines[1:], "\n") + "\n"
+		for _, want := range []string{up.URL + ",true,200,", down.URL + ",false,404,", "waat://furhurterwe.geds,false,,"} {
+			if !strings.Contains(rows, "\n"+want) && !strings.HasPrefix(rows, want) {
+				t.Errorf("got rows %q want one starting %q", rows, want)
+			}
+		}
+	})
+
+	t.Run("reads urls from a file and writes an HTML report", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "urls.txt")
+		if err := os.WriteFile(path, []byte(urls), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var stdout, stderr bytes.Buffer
+
+		code := run(context.Background(), []string{"-format", "html", path}, strings.NewReader(""), &stdout, &stderr)
+
+		assertExitCode(t, code, 0, &stderr)
+		if !strings.Contains(stdout.String(), "3 sites") || !strings.Contains(stdout.String(), "1 up, 2 down") {
+			t.Errorf("got report %s", stdout.String())
+		}
+	})
+
+	t.Run("writes JSON by default", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+
+		code := run(context.Background(), []string{"-"}, strings.NewReader(up.URL), &stdout, &stderr)
+
+		assertExitCode(t, code, 0, &stderr)
+		if !strings.Contains(stdout.String(), `"up": 1`) {
+			t.Errorf("got report %s", stdout.String())
+		}
+	})
+
+	failures := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"unknown format", []string{"-format", "xml"}, 2},
+		{"unknown flag", []string{"-colour"}, 2},
+		{"two files", []string{"a.txt", "b.txt"}, 2},
+		{"missing file", []string{filepath.Join(t.TempDir(), "missing.txt")}, 1},
+		{"help", []string{"-h"}, 0},
+	}
+	for _, f := range failures {
+		t.Run(f.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			code := run(context.Background(), f.args, strings.NewReader(""), &stdout, &stderr)
+
+			assertExitCode(t, code, f.code, &stderr)
+			if stdout.Len() != 0 {
+				t.Errorf("got %q on stdout want nothing", stdout.String())
+			}
+			if stderr.Len() == 0 {
+				t.Error("got nothing on stderr want an explanation")
+			}
+		})
+	}
+}
+
+func assertExitCode(t testing.TB, got, want int, stderr *bytes.Buffer) {
+	t.Helper()
+	if got != want {
+		t.Fatalf("got exit code %d want %d, stderr: %s", got, want, stderr.String())
+	}
+}
@@ -0,0 +1,220 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ErrorKind says why a check failed, so callers can tell a site that is
+// down from one that is slow or doesn't exist.
+type ErrorKind string
+
+const (
+	ErrorNone       ErrorKind = ""
+	ErrorInvalidURL ErrorKind = "invalid_url"
+	ErrorDNS        ErrorKind = "dns"
+	ErrorConnection ErrorKind = "connection"
+	ErrorTimeout    ErrorKind = "timeout"
+	ErrorRedirects  ErrorKind = "too_many_redirects"
+	ErrorStatus     ErrorKind = "status"
+	ErrorCanceled   ErrorKind = "canceled"
+)
+
+// CheckResult is everything learned from checking one url.
+type CheckResult struct {
+	URL string
+	Up  bool
+	// StatusCode is zero if no response came back.
+	StatusCode int
+	// Latency is how long the last attempt took.
+	Latency time.Duration
+	// FinalURL is where any redirects ended up.
+	FinalURL  string
+	Attempts  int
+	ErrorKind ErrorKind
+	Err       error
+}
+
+// DefaultCheckTimeout is how long each attempt gets when an HTTPChecker has
+// no Timeout of its own.
+const DefaultCheckTimeout = 10 * time.Second
+
+const (
+	defaultBackoff    = 200 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+
+	// maxDrain is how much of a GET body is read so the connection can be
+	// reused; anything longer is dropped.
+	maxDrain = 64 * 1024
+)
+
+// HTTPChecker checks a site with a HEAD request, falling back to GET for
+// servers that don't support HEAD. A site is up if it answers with a status
+// below 400. Connection failures, timeouts, 429s and 5xxs are retried with
+// exponential backoff; other failures are final. The zero value is ready to
+// use.
+type HTTPChecker struct {
+	// Client sends the requests; nil means a client that follows up to 10
+	// redirects, made with NewCheckerClient.
+	Client *http.Client
+	// Timeout limits each attempt; zero means 10 seconds.
+	Timeout time.Duration
+	// Retries is how many times to try again after the first attempt.
+	Retries int
+	// Backoff is the wait before the first retry, doubling each time up to
+	// MaxBackoff. Zero means 200ms and 5s.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Check checks url, retrying as configured. It is a DetailedWebsiteChecker.
+func (h *HTTPChecker) Check(ctx context.Context, rawURL string) CheckResult {
+	if _, err := parseHTTPURL(rawURL); err != nil {
+		return CheckResult{URL: rawURL, ErrorKind: ErrorInvalidURL, Err: err}
+	}
+
+	backoff := orDefault(h.Backoff, defaultBackoff)
+	var res CheckResult
+	for attempt := 1; ; attempt++ {
+		res = h.attempt(ctx, rawURL)
+		res.Attempts = attempt
+		if res.Up || !retryable(res) || attempt > h.Retries {
+			return res
+		}
+
+		if err := sleep(ctx, backoff); err != nil {
+			res.ErrorKind, res.Err = ErrorCanceled, err
+			return res
+		}
+		backoff = min(backoff*2, orDefault(h.MaxBackoff, defaultMaxBackoff))
+	}
+}
+
+func (h *HTTPChecker) attempt(ctx context.Context, rawURL string) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, orDefault(h.Timeout, DefaultCheckTimeout))
+	defer cancel()
+
+	start := time.Now()
+	res := CheckResult{URL: rawURL}
+
+	response, err := h.do(ctx, http.MethodHead, rawURL)
+	if err == nil && (response.StatusCode == http.StatusMethodNotAllowed || response.StatusCode == http.StatusNotImplemented) {
+		response.Body.Close()
+		response, err = h.do(ctx, http.MethodGet, rawURL)
+	}
+	if err != nil {
+		res.Latency = time.Since(start)
+		res.ErrorKind, res.Err = classify(err), err
+		return res
+	}
+	io.Copy(io.Discard, io.LimitReader(response.Body, maxDrain))
+	response.Body.Close()
+	res.Latency = time.Since(start)
+
+	res.StatusCode = response.StatusCode
+	res.FinalURL = response.Request.URL.String()
+	res.Up = response.StatusCode < 400
+	if !res.Up {
+		res.ErrorKind = ErrorStatus
+		res.Err = fmt.Errorf("%s answered %s", rawURL, response.Status)
+	}
+	return res
+}
+
+func (h *HTTPChecker) do(ctx context.Context, method, rawURL string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := h.Client
+	if client == nil {
+		client = defaultCheckerClient
+	}
+	return client.Do(request)
+}
+
+// parseHTTPURL accepts only absolute http and https urls.
+func parseHTTPURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%q is not an http or https url", rawURL)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%q has no host", rawURL)
+	}
+	return u, nil
+}
+
+// classify works out the ErrorKind of a failed request.
+func classify(err error) ErrorKind {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case errors.As(err, &dnsErr):
+		return ErrorDNS
+	case errors.Is(err, errTooManyRedirects):
+		return ErrorRedirects
+	}
+	return ErrorConnection
+}
+
+// errTooManyRedirects is what a CheckRedirect has to wrap for the failure to
+// be reported as ErrorRedirects. http.Client's own policy gives up with an
+// error that can't be matched.
+var errTooManyRedirects = errors.New("too many redirects")
+
+var defaultCheckerClient = NewCheckerClient(10)
+
+// NewCheckerClient returns a client for HTTPChecker that follows at most
+// maxRedirects redirects.
+func NewCheckerClient(maxRedirects int) *http.Client {
+	return &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects: %w", maxRedirects, errTooManyRedirects)
+			}
+			return nil
+		},
+	}
+}
+
+func retryable(res CheckResult) bool {
+	switch res.ErrorKind {
+	case ErrorConnection, ErrorTimeout:
+		return true
+	case ErrorStatus:
+		return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+	}
+	return false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func orDefault(d, fallback time.Duration) time.Duration {
+	if d <= 0 {
+		return fallback
+	}
+	return d
+}
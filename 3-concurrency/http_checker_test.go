@@ -0,0 +1,220 @@
+package concurrency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPChecker(t *testing.T) {
+
+	t.Run("up site", func(t *testing.T) {
+		site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer site.Close()
+
+		got := fastChecker().Check(context.Background(), site.URL)
+
+		assertUp(t, got, http.StatusOK)
+		if got.FinalURL != site.URL {
+			t.Errorf("got final url %q want %q", got.FinalURL, site.URL)
+		}
+		if got.Attempts != 1 {
+			t.Errorf("got %d attempts want 1", got.Attempts)
+		}
+		if got.Latency <= 0 {
+			t.Errorf("got latency %v want it measured", got.Latency)
+		}
+	})
+
+	t.Run("falls back to GET when HEAD isn't allowed", func(t *testing.T) {
+		var mu sync.Mutex
+		var methods []string
+		site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			methods = append(methods, r.Method)
+			mu.Unlock()
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		}))
+		defer site.Close()
+
+		got := fastChecker().Check(context.Background(), site.URL)
+
+		assertUp(t, got, http.StatusOK)
+		mu.Lock()
+		defer mu.Unlock()
+		if len(methods) != 2 || methods[0] != http.MethodHead || methods[1] != http.MethodGet {
+			t.Errorf("got requests %v want HEAD then GET", methods)
+		}
+	})
+
+	t.Run("follows redirects", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.Handle("/old", http.RedirectHandler("/new", http.StatusMovedPermanently))
+		mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {})
+		site := httptest.NewServer(mux)
+		defer site.Close()
+
+		got := fastChecker().Check(context.Background(), site.URL+"/old")
+
+		assertUp(t, got, http.StatusOK)
+		if got.FinalURL != site.URL+"/new" {
+			t.Errorf("got final url %q want %q", got.FinalURL, site.URL+"/new")
+		}
+	})
+
+	t.Run("gives up on redirect loops", func(t *testing.T) {
+		site := httptest.NewServer(http.RedirectHandler("/", http.StatusFound))
+		defer site.Close()
+
+		checker := fastChecker()
+		checker.Client = NewCheckerClient(3)
+		got := checker.Check(context.Background(), site.URL)
+
+		assertDown(t, got, ErrorRedirects)
+		if got.Attempts != 1 {
+			t.Errorf("got %d attempts want a redirect loop not to be retried", got.Attempts)
+		}
+	})
+
+	t.Run("times out slow sites and retries them", func(t *testing.T) {
+		var requests int32
+		site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			<-r.Context().Done()
+		}))
+		defer site.Close()
+
+		checker := fastChecker()
+		checker.Retries = 2
+		got := checker.Check(context.Background(), site.URL)
+
+		assertDown(t, got, ErrorTimeout)
+		if got.Attempts != 3 || atomic.LoadInt32(&requests) != 3 {
+			t.Errorf("got %d attempts and %d requests want 3 of each", got.Attempts, requests)
+		}
+	})
+
+	t.Run("retries server errors until the site recovers", func(t *testing.T) {
+		var requests int32
+		site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer site.Close()
+
+		checker := fastChecker()
+		checker.Retries = 5
+		got := checker.Check(context.Background(), site.URL)
+
+		assertUp(t, got, http.StatusOK)
+		if got.Attempts != 3 {
+			t.Errorf("got %d attempts want 3", got.Attempts)
+		}
+	})
+
+	t.Run("doesn't retry client errors", func(t *testing.T) {
+		site := httptest.NewServer(http.NotFoundHandler())
+		defer site.Close()
+
+		checker := fastChecker()
+		checker.Retries = 5
+		got := checker.Check(context.Background(), site.URL)
+
+		assertDown(t, got, ErrorStatus)
+		if got.StatusCode != http.StatusNotFound || got.Attempts != 1 {
+			t.Errorf("got status %d after %d attempts want 404 after 1", got.StatusCode, got.Attempts)
+		}
+	})
+
+	t.Run("reports sites that refuse connections", func(t *testing.T) {
+		site := httptest.NewServer(http.NotFoundHandler())
+		site.Close()
+
+		got := fastChecker().Check(context.Background(), site.URL)
+
+		assertDown(t, got, ErrorConnection)
+	})
+
+	t.Run("rejects urls it can't check without a request", func(t *testing.T) {
+		for _, url := range []string{"waat://furhurterwe.geds", "http://", "not a url", "://"} {
+			got := fastChecker().Check(context.Background(), url)
+
+			assertDown(t, got, ErrorInvalidURL)
+			if got.Attempts != 0 {
+				t.Errorf("%q: got %d attempts want none", url, got.Attempts)
+			}
+		}
+	})
+
+	t.Run("stops retrying when cancelled", func(t *testing.T) {
+		site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer site.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		checker := fastChecker()
+		checker.Retries = 100
+		checker.Backoff = time.Hour
+
+		got := checker.Check(ctx, site.URL)
+
+		assertDown(t, got, ErrorCanceled)
+		if got.Attempts != 1 {
+			t.Errorf("got %d attempts want 1", got.Attempts)
+		}
+	})
+}
+
+func TestCheckWebsitesDetailed(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer slow.Close()
+
+	checker := fastChecker()
+	got, err := CheckWebsitesDetailed(context.Background(), checker.Check, []string{up.URL, down.URL, slow.URL, "waat://furhurterwe.geds"}, 2)
+
+	if err != nil {
+		t.Fatalf("didn't expect an error but got one, %v", err)
+	}
+	assertUp(t, got[up.URL], http.StatusOK)
+	assertDown(t, got[down.URL], ErrorStatus)
+	assertDown(t, got[slow.URL], ErrorTimeout)
+	assertDown(t, got["waat://furhurterwe.geds"], ErrorInvalidURL)
+}
+
+func fastChecker() *HTTPChecker {
+	return &HTTPChecker{
+		Timeout: 50 * time.Millisecond,
+		Backoff: time.Millisecond,
+	}
+}
+
+func assertUp(t testing.TB, got CheckResult, wantStatus int) {
+	t.Helper()
+	if !got.Up || got.StatusCode != wantStatus || got.ErrorKind != ErrorNone || got.Err != nil {
+		t.Errorf("got %+v want up with status %d", got, wantStatus)
+	}
+}
+
+func assertDown(t testing.TB, got CheckResult, wantKind ErrorKind) {
+	t.Helper()
+	if got.Up || got.ErrorKind != wantKind || got.Err == nil {
+		t.Errorf("got %+v want down with %q", got, wantKind)
+	}
+}
@@ -1,38 +1,266 @@
-package main
+package concurrency
 
 import (
+	"context"
 	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type WebsiteChecker func(string) bool
-type result struct {
-	string
-	bool
+
+// DetailedWebsiteChecker checks a url and says why it is up or down.
+type DetailedWebsiteChecker func(ctx context.Context, url string) CheckResult
+
+type result[R any] struct {
+	url    string
+	result R
+	took   time.Duration
 }
 
-func CheckWebsites(wc WebsiteChecker, urls []string) map[string]bool {
-	results := make(map[string]bool)
-	resultsChannel := make(chan result)
+// CheckWebsites checks every url at once and waits for all of them.
+func CheckWebsites(wc WebsiteChecker, urls []string, opts ...Option) map[string]bool {
+	results, _ := CheckWebsitesContext(context.Background(), wc, urls, 0, opts...)
+	return results
+}
 
-	start := time.Now()
-	for _, url := range urls {
-		go func(u string) {
-			start := time.Now()
-			resultsChannel <- result{u, wc(u)}
-			//results[u] = wc(u)
-			fmt.Println("go routine time: ", time.Since(start))
-		}(url)
+// CheckWebsitesContext checks the urls with at most maxConcurrency checks
+// running at a time; zero or less means no limit. Urls that only differ in
+// case, default port or a trailing slash are checked once, and ones that
+// aren't http or https urls are reported down without being checked. The
+// results are keyed by the urls as they were given. Once ctx is cancelled no
+// more checks are started and it returns the results it has so far along
+// with an error wrapping ctx.Err(). Checks already running can't be stopped,
+// so they finish in the background and their results are dropped.
+func CheckWebsitesContext(ctx context.Context, wc WebsiteChecker, urls []string, maxConcurrency int, opts ...Option) (map[string]bool, error) {
+	invalid := func(string, error) bool { return false }
+	return checkAll(ctx, urls, maxConcurrency, wc, invalid, newOptions(opts))
+}
+
+// CheckWebsitesDetailed is CheckWebsitesContext for a checker that reports
+// more than up or down. ctx is handed on to every check, so cancelling it
+// also stops the checks that are running.
+func CheckWebsitesDetailed(ctx context.Context, dc DetailedWebsiteChecker, urls []string, maxConcurrency int, opts ...Option) (map[string]CheckResult, error) {
+	check := func(url string) CheckResult { return dc(ctx, url) }
+	invalid := func(url string, err error) CheckResult {
+		return CheckResult{URL: url, ErrorKind: ErrorInvalidURL, Err: err}
 	}
+	return checkAll(ctx, urls, maxConcurrency, check, invalid, newOptions(opts))
+}
 
-	for i := 0; i < len(urls); i++ {
-		r := <-resultsChannel
-		results[r.string] = r.bool
+// checkAll checks each url once, however many times and in whatever form it
+// was asked for, and files the result under every url that asked for it.
+// Urls that can't be checked get invalid's result without a check.
+func checkAll[R any](ctx context.Context, urls []string, maxConcurrency int, check func(string) R, invalid func(string, error) R, o options) (map[string]R, error) {
+	plan := planChecks(urls)
+	unique := plan.unique
+	if maxConcurrency <= 0 || maxConcurrency > len(unique) {
+		maxConcurrency = len(unique)
 	}
 
-	stop := time.Since(start)
-	fmt.Println("total time: ", stop)
+	results := make(map[string]R)
+	for url, err := range plan.invalid {
+		results[url] = invalid(url, err)
+	}
+	// buffered so checks still running after a cancellation don't block forever
+	resultsChannel := make(chan result[R], len(unique))
+	slots := make(chan struct{}, maxConcurrency)
 
-	time.Sleep(5 * time.Second)
-	return results
+	start := o.clock.Now()
+	if o.observer != nil {
+		defer func() { o.observer.AllDone(o.clock.Now().Sub(start)) }()
+	}
+
+	var launched atomic.Int64
+	var dispatchers, checks sync.WaitGroup
+	launch := func(u string, done func()) {
+		launched.Add(1)
+		checks.Add(1)
+		go func() {
+			defer checks.Done()
+			defer func() {
+				<-slots
+				done()
+			}()
+			start := o.clock.Now()
+			r := check(u)
+			resultsChannel <- result[R]{u, r, o.clock.Now().Sub(start)}
+		}()
+	}
+
+	for _, h := range o.hosts(unique) {
+		dispatchers.Add(1)
+		go func(h *host) {
+			defer dispatchers.Done()
+			h.dispatch(ctx, o.clock, slots, launch)
+		}(h)
+	}
+	go func() {
+		// checks are only launched by dispatchers, so once they are done
+		// nothing else is added to checks
+		dispatchers.Wait()
+		checks.Wait()
+		close(resultsChannel)
+	}()
+
+	received := 0
+	for {
+		select {
+		case r, ok := <-resultsChannel:
+			if !ok {
+				if int(launched.Load()) < len(unique) {
+					return results, cancelled(ctx, received, len(unique))
+				}
+				return results, nil
+			}
+			received++
+			collect(results, r, plan.asked[r.url], o.observer)
+		case <-ctx.Done():
+			// keep the checks that had already finished
+			for len(resultsChannel) > 0 {
+				r, ok := <-resultsChannel
+				if !ok {
+					break
+				}
+				received++
+				collect(results, r, plan.asked[r.url], o.observer)
+			}
+			return results, cancelled(ctx, received, len(unique))
+		}
+	}
+}
+
+// host is the urls on one host and the limits on checking them.
+type host struct {
+	urls  []string
+	every time.Duration
+	// slots is nil when there is no limit on concurrent checks.
+	slots  chan struct{}
+	queued func(url string)
+}
+
+// hosts groups the urls by host, in the order each host first appears. With
+// no host limits they all go in one group so they are launched in order.
+func (o options) hosts(urls []string) []*host {
+	newHost := func() *host {
+		h := &host{every: o.hostEvery, queued: o.queued}
+		if o.hostConcurrency > 0 {
+			h.slots = make(chan struct{}, o.hostConcurrency)
+		}
+		return h
+	}
+
+	if o.hostEvery <= 0 && o.hostConcurrency <= 0 {
+		all := newHost()
+		all.urls = urls
+		return []*host{all}
+	}
+
+	var hosts []*host
+	byName := map[string]*host{}
+	for _, u := range urls {
+		name := hostOf(u)
+		h, ok := byName[name]
+		if !ok {
+			h = newHost()
+			byName[name] = h
+			hosts = append(hosts, h)
+		}
+		h.urls = append(h.urls, u)
+	}
+	return hosts
+}
+
+// hostOf is the host:port of u, or u itself if it can't be parsed.
+func hostOf(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" {
+		return u
+	}
+	return strings.ToLower(parsed.Host)
+}
+
+// dispatch launches a check for each of the host's urls once the host's
+// limits and the overall slots allow it, stopping when ctx is cancelled.
+func (h *host) dispatch(ctx context.Context, clock Clock, slots chan struct{}, launch func(u string, done func())) {
+	var next time.Time
+	for _, u := range h.urls {
+		if h.slots != nil {
+			if !acquire(ctx, h.slots) {
+				return
+			}
+		}
+		if h.every > 0 {
+			if !waitUntil(ctx, clock, next) {
+				h.release()
+				return
+			}
+		}
+		if h.queued != nil && len(slots) == cap(slots) {
+			h.queued(u)
+		}
+		if !acquire(ctx, slots) {
+			h.release()
+			return
+		}
+
+		// spaced from when the check starts, not from when it could have,
+		// so time spent waiting for a slot doesn't count towards the gap
+		if h.every > 0 {
+			next = clock.Now().Add(h.every)
+		}
+		launch(u, h.release)
+	}
+}
+
+// release hands back a host slot, if the host has any.
+func (h *host) release() {
+	if h.slots != nil {
+		<-h.slots
+	}
+}
+
+// waitUntil waits for clock to reach t, giving up if ctx is cancelled first.
+func waitUntil(ctx context.Context, clock Clock, t time.Time) bool {
+	wait := t.Sub(clock.Now())
+	if wait <= 0 {
+		return ctx.Err() == nil
+	}
+	select {
+	case <-clock.After(wait):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// collect files a finished check's result under every url that asked for it
+// and tells the observer, if there is one, how long it took.
+func collect[R any](results map[string]R, r result[R], asked []string, observer Observer) {
+	for _, url := range asked {
+		results[url] = r.result
+	}
+	if observer != nil {
+		observer.CheckDone(r.url, r.took)
+	}
+}
+
+// acquire waits for a free slot, giving up if ctx is cancelled first.
+func acquire(ctx context.Context, slots chan struct{}) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func cancelled(ctx context.Context, checked, total int) error {
+	return fmt.Errorf("checked %d of %d websites before stopping: %w", checked, total, ctx.Err())
 }
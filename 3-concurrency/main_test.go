@@ -1,7 +1,14 @@
-package main
+package concurrency
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -40,19 +47,502 @@ func TestCheckWebsites(t *testing.T) {
 }
 
 func TestGoRoutine(t *testing.T) {
-	urls := make([]string, 80)
-	for i := 0; i < len(urls); i++ {
-		urls[i] = "a url"
-	}
+	urls := numberedURLs(80)
 
 	CheckWebsites(slowWebsiteChecker, urls)
 }
 
-func BenchmarkCheckWebsites(b *testing.B) {
-	urls := make([]string, 20)
-	for i := 0; i < len(urls); i++ {
-		urls[i] = "a url"
+func TestCheckWebsitesContext(t *testing.T) {
+
+	t.Run("never runs more than maxConcurrency checks at once", func(t *testing.T) {
+		var running, most int32
+		checker := func(string) bool {
+			now := atomic.AddInt32(&running, 1)
+			for {
+				seen := atomic.LoadInt32(&most)
+				if now <= seen || atomic.CompareAndSwapInt32(&most, seen, now) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return true
+		}
+
+		got, err := CheckWebsitesContext(context.Background(), checker, numberedURLs(20), 3)
+
+		if err != nil {
+			t.Fatalf("didn't expect an error but got one, %v", err)
+		}
+		if len(got) != 20 {
+			t.Errorf("got %d results want %d", len(got), 20)
+		}
+		if most > 3 {
+			t.Errorf("got %d checks running at once want at most %d", most, 3)
+		}
+	})
+
+	t.Run("stops launching checks once cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		release := make(chan struct{})
+		defer close(release)
+
+		var mu sync.Mutex
+		started := 0
+		checker := func(url string) bool {
+			mu.Lock()
+			started++
+			mu.Unlock()
+			if url != "http://site-0.example" {
+				<-release
+			}
+			return true
+		}
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			cancel()
+		}()
+
+		got, err := CheckWebsitesContext(ctx, checker, numberedURLs(10), 2)
+
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v want %v", err, context.Canceled)
+		}
+		if want := map[string]bool{"http://site-0.example": true}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got partial results %v want %v", got, want)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if started != 3 {
+			t.Errorf("started %d checks want %d", started, 3)
+		}
+	})
+
+	t.Run("checks nothing when already cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var calls int32
+		checker := func(string) bool {
+			atomic.AddInt32(&calls, 1)
+			return true
+		}
+
+		got, err := CheckWebsitesContext(ctx, checker, numberedURLs(5), 0)
+
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v want %v", err, context.Canceled)
+		}
+		if len(got) != 0 || calls != 0 {
+			t.Errorf("got %v from %d checks want none", got, calls)
+		}
+	})
+}
+
+func TestCheckWebsitesDeduplicates(t *testing.T) {
+
+	t.Run("checks each url once however it is written", func(t *testing.T) {
+		var mu sync.Mutex
+		calls := map[string]int{}
+		checker := func(url string) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[url]++
+			return true
+		}
+		urls := []string{
+			"http://google.com",
+			"HTTP://Google.com:80/",
+			"http://google.com",
+			"https://google.com:443",
+			"https://google.com/search/",
+		}
+
+		got := CheckWebsites(checker, urls)
+
+		wantCalls := map[string]int{
+			"http://google.com":         1,
+			"https://google.com":        1,
+			"https://google.com/search": 1,
+		}
+		if !reflect.DeepEqual(calls, wantCalls) {
+			t.Errorf("got checks %v want %v", calls, wantCalls)
+		}
+		for _, url := range urls {
+			if !got[url] {
+				t.Errorf("got no result for %q, %v", url, got)
+			}
+		}
+		if len(got) != 4 {
+			t.Errorf("got %d results want one for each distinct url asked for, %v", len(got), got)
+		}
+	})
+
+	t.Run("reports invalid urls without checking them", func(t *testing.T) {
+		var calls int32
+		checker := func(url string) bool {
+			atomic.AddInt32(&calls, 1)
+			return true
+		}
+
+		got := CheckWebsites(checker, []string{"waat://furhurterwe.geds", "http://", "not a url"})
+
+		if calls != 0 {
+			t.Errorf("checker was called %d times want none", calls)
+		}
+		want := map[string]bool{"waat://furhurterwe.geds": false, "http://": false, "not a url": false}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v want %v", got, want)
+		}
+	})
+
+	t.Run("gives invalid urls a parse error result", func(t *testing.T) {
+		checker := func(ctx context.Context, url string) CheckResult {
+			t.Errorf("checker called for %q", url)
+			return CheckResult{}
+		}
+
+		got, err := CheckWebsitesDetailed(context.Background(), checker, []string{"waat://furhurterwe.geds"}, 0)
+
+		if err != nil {
+			t.Fatalf("didn't expect an error but got one, %v", err)
+		}
+		assertDown(t, got["waat://furhurterwe.geds"], ErrorInvalidURL)
+	})
+}
+
+func TestCheckWebsitesTimings(t *testing.T) {
+
+	t.Run("reports each check and the total to the observer", func(t *testing.T) {
+		clock := &FakeClock{now: monitorStart}
+		observer := &SpyObserver{}
+		took := map[string]time.Duration{
+			"http://site-0.example": 2 * time.Second,
+			"http://site-1.example": 3 * time.Second,
+			"http://site-2.example": 500 * time.Millisecond,
+		}
+		checker := func(url string) bool {
+			clock.Advance(took[url])
+			return true
+		}
+
+		_, err := CheckWebsitesContext(context.Background(), checker, numberedURLs(3), 1, WithClock(clock), WithObserver(observer))
+
+		if err != nil {
+			t.Fatalf("didn't expect an error but got one, %v", err)
+		}
+		if !reflect.DeepEqual(observer.checks, took) {
+			t.Errorf("got check timings %v want %v", observer.checks, took)
+		}
+		if want := []time.Duration{5500 * time.Millisecond}; !reflect.DeepEqual(observer.totals, want) {
+			t.Errorf("got total timings %v want %v", observer.totals, want)
+		}
+	})
+
+	t.Run("reports the total when cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		observer := &SpyObserver{}
+
+		CheckWebsitesContext(ctx, mockWebsiteChecker, numberedURLs(3), 0, WithClock(&FakeClock{}), WithObserver(observer))
+
+		if len(observer.checks) != 0 || !reflect.DeepEqual(observer.totals, []time.Duration{0}) {
+			t.Errorf("got checks %v and totals %v want no checks and a zero total", observer.checks, observer.totals)
+		}
+	})
+
+	t.Run("writes nothing to stdout", func(t *testing.T) {
+		got := captureStdout(t, func() {
+			CheckWebsites(mockWebsiteChecker, numberedURLs(10))
+		})
+
+		if got != "" {
+			t.Errorf("got %q on stdout want nothing", got)
+		}
+	})
+}
+
+func TestCheckWebsitesPerHostLimits(t *testing.T) {
+	urls := []string{
+		"http://a.example/1", "http://a.example/2", "http://a.example/3",
+		"http://b.example/1", "http://b.example/2",
+	}
+
+	t.Run("spaces out checks on the same host but not across hosts", func(t *testing.T) {
+		clock := &FakeClock{now: monitorStart}
+		var mu sync.Mutex
+		started := map[string]time.Duration{}
+		checker := func(url string) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			started[url] = clock.Now().Sub(monitorStart)
+			return true
+		}
+
+		done := make(chan map[string]bool)
+		go func() {
+			got, _ := CheckWebsitesContext(context.Background(), checker, urls, 0, WithClock(clock), WithHostRateLimit(time.Second))
+			done <- got
+		}()
+
+		startedChecks := func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(started)
+		}
+
+		// a and b each wait for their second check, then a for its third.
+		// The clock only moves once the checks launched have read it.
+		waitFor(t, func() bool { return startedChecks() == 2 && clock.Waiting() == 2 })
+		clock.Advance(time.Second)
+		waitFor(t, func() bool { return startedChecks() == 4 && clock.Waiting() == 1 })
+		clock.Advance(time.Second)
+
+		if got := <-done; len(got) != len(urls) {
+			t.Fatalf("got %d results want %d", len(got), len(urls))
+		}
+		want := map[string]time.Duration{
+			"http://a.example/1": 0,
+			"http://a.example/2": time.Second,
+			"http://a.example/3": 2 * time.Second,
+			"http://b.example/1": 0,
+			"http://b.example/2": time.Second,
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !reflect.DeepEqual(started, want) {
+			t.Errorf("got checks started at %v want %v", started, want)
+		}
+	})
+
+	t.Run("caps checks running on each host", func(t *testing.T) {
+		var mu sync.Mutex
+		running, most := map[string]int{}, map[string]int{}
+		total, mostTotal := 0, 0
+		checker := func(url string) bool {
+			host := hostOf(url)
+			mu.Lock()
+			running[host]++
+			total++
+			most[host] = max(most[host], running[host])
+			mostTotal = max(mostTotal, total)
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			running[host]--
+			total--
+			mu.Unlock()
+			return true
+		}
+
+		got, err := CheckWebsitesContext(context.Background(), checker, urls, 0, WithHostConcurrency(1))
+
+		if err != nil || len(got) != len(urls) {
+			t.Fatalf("got %d results and error %v want %d and none", len(got), err, len(urls))
+		}
+		if want := map[string]int{"a.example": 1, "b.example": 1}; !reflect.DeepEqual(most, want) {
+			t.Errorf("got at most %v checks at once per host want %v", most, want)
+		}
+		if mostTotal != 2 {
+			t.Errorf("got at most %d checks at once want the two hosts in parallel", mostTotal)
+		}
+	})
+
+	t.Run("spaces checks from when they start, not from when they queue for a slot", func(t *testing.T) {
+		clock := &FakeClock{now: monitorStart}
+		slowStarted, slow := make(chan struct{}), make(chan struct{})
+		var mu sync.Mutex
+		var aStarted []time.Duration
+		checker := func(url string) bool {
+			if url == "http://b.example/1" {
+				close(slowStarted)
+				<-slow
+				return true
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			aStarted = append(aStarted, clock.Now().Sub(monitorStart))
+			return true
+		}
+		startedA := func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(aStarted)
+		}
+		var aQueued atomic.Int64
+		queued := func(o *options) {
+			o.queued = func(url string) {
+				if hostOf(url) == "a.example" {
+					aQueued.Add(1)
+				}
+			}
+		}
+		urls := []string{"http://b.example/1", "http://a.example/1", "http://a.example/2", "http://a.example/3"}
+
+		done := make(chan map[string]bool)
+		go func() {
+			got, _ := CheckWebsitesContext(context.Background(), checker, urls, 1, WithClock(clock), WithHostRateLimit(time.Second), queued)
+			done <- got
+		}()
+
+		// a.example queues behind b.example's slow check for five seconds,
+		// having maybe got its first check in before it and so waiting a
+		// second to queue
+		<-slowStarted
+		waitFor(t, func() bool { return aQueued.Load() == 1 || clock.Waiting() == 1 })
+		already := startedA()
+		clock.Advance(time.Second)
+		waitFor(t, func() bool { return aQueued.Load() == 1 })
+		clock.Advance(4 * time.Second)
+		close(slow)
+
+		// then the clock only moves once a.example has read it and is
+		// waiting to check again
+		for n := already + 1; n <= 3; n++ {
+			waitFor(t, func() bool { return startedA() >= n })
+			if n == 3 {
+				break
+			}
+			waitFor(t, func() bool { return clock.Waiting() == 1 || startedA() > n })
+			if clock.Waiting() == 1 {
+				clock.Advance(time.Second)
+			}
+		}
+
+		if got := <-done; len(got) != len(urls) {
+			t.Fatalf("got %d results want %d", len(got), len(urls))
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for i := 1; i < len(aStarted); i++ {
+			if gap := aStarted[i] - aStarted[i-1]; gap < time.Second {
+				t.Errorf("got a.example checks started at %v, only %v apart", aStarted, gap)
+			}
+		}
+	})
+
+	t.Run("stops waiting for a host when cancelled", func(t *testing.T) {
+		clock := &FakeClock{now: monitorStart}
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan error)
+		go func() {
+			_, err := CheckWebsitesContext(ctx, mockWebsiteChecker, urls, 0, WithClock(clock), WithHostRateLimit(time.Hour))
+			done <- err
+		}()
+		waitFor(t, func() bool { return clock.Waiting() == 2 })
+		cancel()
+
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v want %v", err, context.Canceled)
+		}
+	})
+}
+
+// FakeClock only moves when it is told to.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	until time.Time
+	ch    chan time.Time
+}
+
+func (f *FakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	f.waiters = append(f.waiters, fakeWaiter{f.now.Add(d), ch})
+	return ch
+}
+
+// Waiting is how many calls to After haven't fired yet.
+func (f *FakeClock) Waiting() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Advance moves the clock on, firing any After whose time has come.
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+
+	waiting := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.until.After(f.now) {
+			waiting = append(waiting, w)
+			continue
+		}
+		w.ch <- f.now
+	}
+	f.waiters = waiting
+}
+
+type SpyObserver struct {
+	checks map[string]time.Duration
+	totals []time.Duration
+}
+
+func (s *SpyObserver) CheckDone(url string, took time.Duration) {
+	if s.checks == nil {
+		s.checks = map[string]time.Duration{}
+	}
+	s.checks[url] = took
+}
+
+func (s *SpyObserver) AllDone(took time.Duration) {
+	s.totals = append(s.totals, took)
+}
+
+func captureStdout(t testing.TB, f func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("could not create pipe, %v", err)
 	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		written, _ := io.ReadAll(r)
+		output <- string(written)
+	}()
+
+	f()
+	w.Close()
+	return <-output
+}
+
+func numberedURLs(n int) []string {
+	urls := make([]string, n)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("http://site-%d.example", i)
+	}
+	return urls
+}
+
+func BenchmarkCheckWebsites(b *testing.B) {
+	urls := numberedURLs(20)
 
 	b.ResetTimer()
 	for i := 0; i < 1; i++ {
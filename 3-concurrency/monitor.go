@@ -0,0 +1,271 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Ticker tells the Monitor when to check again. It is Sleeper's counterpart
+// for things that repeat, so tests can hand out ticks themselves.
+type Ticker interface {
+	Ticks() <-chan time.Time
+	Stop()
+}
+
+// DefaultTicker ticks on a time.Ticker.
+type DefaultTicker struct {
+	ticker *time.Ticker
+}
+
+func NewDefaultTicker(d time.Duration) Ticker {
+	return &DefaultTicker{time.NewTicker(d)}
+}
+
+func (d *DefaultTicker) Ticks() <-chan time.Time {
+	return d.ticker.C
+}
+
+func (d *DefaultTicker) Stop() {
+	d.ticker.Stop()
+}
+
+// State is whether a url was up when it was last checked.
+type State string
+
+const (
+	StateUnknown State = "unknown"
+	StateUp      State = "up"
+	StateDown    State = "down"
+)
+
+func stateOf(res CheckResult) State {
+	if res.Up {
+		return StateUp
+	}
+	return StateDown
+}
+
+// Event is a url changing state. The first check of a url is a change from
+// StateUnknown.
+type Event struct {
+	URL    string
+	From   State
+	To     State
+	At     time.Time
+	Result CheckResult
+}
+
+// Notifier is told about every Event.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// Target is a url and how often to check it.
+type Target struct {
+	URL      string
+	Interval time.Duration
+}
+
+// Status is what the Monitor knows about one url.
+type Status struct {
+	URL   string
+	State State
+	// Since is when the url went into State.
+	Since    time.Time
+	Checks   int
+	UpChecks int
+	Last     CheckResult
+	// Transitions holds the most recent state changes, oldest first.
+	Transitions []Event
+}
+
+// Uptime is the percentage of checks that found the url up.
+func (s Status) Uptime() float64 {
+	if s.Checks == 0 {
+		return 0
+	}
+	return 100 * float64(s.UpChecks) / float64(s.Checks)
+}
+
+const defaultHistorySize = 100
+
+// Monitor checks each Target every Interval until it is stopped, keeping a
+// Status per url and passing state changes to the Notifier. Targets sharing
+// an interval are checked together with CheckWebsitesDetailed. The first
+// check happens on the first tick, not when Run is called.
+type Monitor struct {
+	Checker  DetailedWebsiteChecker
+	Notifier Notifier
+	// NewTicker makes the ticker for each interval; nil means
+	// NewDefaultTicker.
+	NewTicker func(d time.Duration) Ticker
+	// MaxConcurrency limits the checks run at once for each interval; zero
+	// means no limit.
+	MaxConcurrency int
+	// HistorySize is how many transitions to keep per url; zero means 100.
+	HistorySize int
+
+	mu       sync.RWMutex
+	statuses map[string]*Status
+}
+
+// Run checks the targets until ctx is cancelled and then returns nil, once
+// any checks and notifications in progress are done. It only returns an
+// error if the targets can't be monitored.
+func (m *Monitor) Run(ctx context.Context, targets []Target) error {
+	schedule, err := m.schedule(targets)
+	if err != nil {
+		return err
+	}
+
+	newTicker := m.NewTicker
+	if newTicker == nil {
+		newTicker = NewDefaultTicker
+	}
+
+	var wg sync.WaitGroup
+	for interval, urls := range schedule {
+		ticker := newTicker(interval)
+		wg.Add(1)
+		go func(urls []string) {
+			defer wg.Done()
+			defer ticker.Stop()
+			m.watch(ctx, ticker, urls)
+		}(urls)
+	}
+	wg.Wait()
+	return nil
+}
+
+// schedule groups the target urls by interval and sets up their statuses.
+func (m *Monitor) schedule(targets []Target) (map[time.Duration][]string, error) {
+	if m.Checker == nil {
+		return nil, errors.New("monitor needs a Checker")
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("monitor needs at least one target")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.statuses == nil {
+		m.statuses = map[string]*Status{}
+	}
+
+	schedule := map[time.Duration][]string{}
+	seen := map[string]bool{}
+	for _, target := range targets {
+		if target.Interval <= 0 {
+			return nil, fmt.Errorf("interval for %s must be positive, got %v", target.URL, target.Interval)
+		}
+		if _, ok := m.statuses[target.URL]; ok || seen[target.URL] {
+			return nil, fmt.Errorf("%s is already being monitored", target.URL)
+		}
+		seen[target.URL] = true
+		schedule[target.Interval] = append(schedule[target.Interval], target.URL)
+	}
+
+	for url := range seen {
+		m.statuses[url] = &Status{URL: url, State: StateUnknown}
+	}
+	return schedule, nil
+}
+
+func (m *Monitor) watch(ctx context.Context, ticker Ticker, urls []string) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case at := <-ticker.Ticks():
+			results, err := CheckWebsitesDetailed(ctx, m.Checker, urls, m.MaxConcurrency)
+			if err != nil {
+				return
+			}
+			for _, url := range urls {
+				if e, changed := m.record(url, at, results[url]); changed {
+					m.notify(ctx, e)
+				}
+			}
+		}
+	}
+}
+
+// record updates the url's status with a check made at at, reporting the
+// Event if its state changed.
+func (m *Monitor) record(url string, at time.Time, res CheckResult) (Event, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := m.statuses[url]
+	status.Checks++
+	if res.Up {
+		status.UpChecks++
+	}
+	status.Last = res
+
+	to := stateOf(res)
+	if to == status.State {
+		return Event{}, false
+	}
+
+	e := Event{URL: url, From: status.State, To: to, At: at, Result: res}
+	status.State = to
+	status.Since = at
+	status.Transitions = append(status.Transitions, e)
+	if keep := m.historySize(); len(status.Transitions) > keep {
+		status.Transitions = append([]Event{}, status.Transitions[len(status.Transitions)-keep:]...)
+	}
+	return e, true
+}
+
+func (m *Monitor) notify(ctx context.Context, e Event) {
+	if m.Notifier == nil {
+		return
+	}
+	if err := m.Notifier.Notify(ctx, e); err != nil {
+		log.Printf("problem notifying that %s is %s, %v", e.URL, e.To, err)
+	}
+}
+
+func (m *Monitor) historySize() int {
+	if m.HistorySize <= 0 {
+		return defaultHistorySize
+	}
+	return m.HistorySize
+}
+
+// Status returns a copy of what is known about url.
+func (m *Monitor) Status(url string) (Status, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status, ok := m.statuses[url]
+	if !ok {
+		return Status{}, false
+	}
+	return copyStatus(status), true
+}
+
+// Statuses returns every url's status, sorted by url.
+func (m *Monitor) Statuses() []Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make([]Status, 0, len(m.statuses))
+	for _, status := range m.statuses {
+		statuses = append(statuses, copyStatus(status))
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].URL < statuses[j].URL })
+	return statuses
+}
+
+func copyStatus(s *Status) Status {
+	c := *s
+	c.Transitions = append([]Event{}, s.Transitions...)
+	return c
+}
@@ -0,0 +1,313 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// FakeTicker only ticks when the test says so. Tick blocks until the
+// monitor takes the tick.
+type FakeTicker struct {
+	ticks   chan time.Time
+	mu      sync.Mutex
+	stopped bool
+}
+
+func (f *FakeTicker) Ticks() <-chan time.Time {
+	return f.ticks
+}
+
+func (f *FakeTicker) Stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = true
+}
+
+func (f *FakeTicker) Tick(at time.Time) {
+	f.ticks <- at
+}
+
+func (f *FakeTicker) Stopped() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stopped
+}
+
+// FakeTickers hands a FakeTicker to the monitor for each interval and lets
+// the test find it again.
+type FakeTickers struct {
+	mu      sync.Mutex
+	tickers map[time.Duration]*FakeTicker
+}
+
+func (f *FakeTickers) NewTicker(d time.Duration) Ticker {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.tickers == nil {
+		f.tickers = map[time.Duration]*FakeTicker{}
+	}
+	ticker := &FakeTicker{ticks: make(chan time.Time)}
+	f.tickers[d] = ticker
+	return ticker
+}
+
+func (f *FakeTickers) For(t testing.TB, d time.Duration) *FakeTicker {
+	t.Helper()
+	var ticker *FakeTicker
+	waitFor(t, func() bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		ticker = f.tickers[d]
+		return ticker != nil
+	})
+	return ticker
+}
+
+// ScriptedChecker answers each url with the next of its scripted results.
+type ScriptedChecker struct {
+	mu      sync.Mutex
+	results map[string][]bool
+}
+
+func (s *ScriptedChecker) Check(_ context.Context, url string) CheckResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	up := s.results[url][0]
+	s.results[url] = s.results[url][1:]
+	if up {
+		return CheckResult{URL: url, Up: true, StatusCode: 200}
+	}
+	return CheckResult{URL: url, StatusCode: 503, ErrorKind: ErrorStatus, Err: errors.New("503 Service Unavailable")}
+}
+
+type SpyNotifier struct {
+	mu     sync.Mutex
+	events []Event
+	err    error
+}
+
+func (s *SpyNotifier) Notify(_ context.Context, e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return s.err
+}
+
+// transitions lists the From and To of every event for url.
+func (s *SpyNotifier) transitions(url string) []State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var states []State
+	for _, e := range s.events {
+		if e.URL == url {
+			states = append(states, e.From, e.To)
+		}
+	}
+	return states
+}
+
+var monitorStart = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+func TestMonitor(t *testing.T) {
+
+	t.Run("tracks state changes and uptime per url", func(t *testing.T) {
+		tickers := &FakeTickers{}
+		notifier := &SpyNotifier{}
+		checker := &ScriptedChecker{results: map[string][]bool{
+			"http://a.example": {true, true, false},
+			"http://b.example": {false, true, true},
+			"http://c.example": {true},
+		}}
+		monitor := &Monitor{Checker: checker.Check, Notifier: notifier, NewTicker: tickers.NewTicker}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := runMonitor(monitor, ctx, []Target{
+			{"http://a.example", time.Minute},
+			{"http://b.example", time.Minute},
+			{"http://c.example", 5 * time.Minute},
+		})
+
+		everyMinute := tickers.For(t, time.Minute)
+		everyFive := tickers.For(t, 5*time.Minute)
+		for i := 1; i <= 3; i++ {
+			everyMinute.Tick(monitorStart.Add(time.Duration(i) * time.Minute))
+			waitForChecks(t, monitor, "http://a.example", i)
+		}
+		everyFive.Tick(monitorStart.Add(5 * time.Minute))
+		waitForChecks(t, monitor, "http://c.example", 1)
+
+		cancel()
+		assertMonitorStopped(t, done)
+
+		assertStates(t, notifier.transitions("http://a.example"), StateUnknown, StateUp, StateUp, StateDown)
+		assertStates(t, notifier.transitions("http://b.example"), StateUnknown, StateDown, StateDown, StateUp)
+		assertStates(t, notifier.transitions("http://c.example"), StateUnknown, StateUp)
+
+		a, _ := monitor.Status("http://a.example")
+		assertUptime(t, a, 100*2.0/3.0)
+		if a.State != StateDown || !a.Since.Equal(monitorStart.Add(3*time.Minute)) {
+			t.Errorf("got a %s since %v want down since the third minute", a.State, a.Since)
+		}
+		if a.Last.StatusCode != 503 {
+			t.Errorf("got last result %+v want the 503", a.Last)
+		}
+
+		b, _ := monitor.Status("http://b.example")
+		assertUptime(t, b, 100*2.0/3.0)
+		if len(b.Transitions) != 2 {
+			t.Errorf("got transitions %v want 2", b.Transitions)
+		}
+
+		if !everyMinute.Stopped() || !everyFive.Stopped() {
+			t.Error("tickers weren't stopped")
+		}
+	})
+
+	t.Run("keeps only the most recent transitions", func(t *testing.T) {
+		tickers := &FakeTickers{}
+		checker := &ScriptedChecker{results: map[string][]bool{
+			"http://a.example": {true, false, true, false, true},
+		}}
+		monitor := &Monitor{Checker: checker.Check, NewTicker: tickers.NewTicker, HistorySize: 2}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := runMonitor(monitor, ctx, []Target{{"http://a.example", time.Second}})
+		ticker := tickers.For(t, time.Second)
+		for i := 1; i <= 5; i++ {
+			ticker.Tick(monitorStart.Add(time.Duration(i) * time.Second))
+			waitForChecks(t, monitor, "http://a.example", i)
+		}
+		cancel()
+		assertMonitorStopped(t, done)
+
+		status, _ := monitor.Status("http://a.example")
+		var states []State
+		for _, e := range status.Transitions {
+			states = append(states, e.From, e.To)
+		}
+		assertStates(t, states, StateUp, StateDown, StateDown, StateUp)
+		assertUptime(t, status, 60)
+	})
+
+	t.Run("carries on when the notifier fails", func(t *testing.T) {
+		tickers := &FakeTickers{}
+		notifier := &SpyNotifier{err: errors.New("no one is listening")}
+		checker := &ScriptedChecker{results: map[string][]bool{"http://a.example": {true, false}}}
+		monitor := &Monitor{Checker: checker.Check, Notifier: notifier, NewTicker: tickers.NewTicker}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := runMonitor(monitor, ctx, []Target{{"http://a.example", time.Second}})
+		ticker := tickers.For(t, time.Second)
+		ticker.Tick(monitorStart)
+		ticker.Tick(monitorStart.Add(time.Second))
+		waitForChecks(t, monitor, "http://a.example", 2)
+		cancel()
+		assertMonitorStopped(t, done)
+
+		assertStates(t, notifier.transitions("http://a.example"), StateUnknown, StateUp, StateUp, StateDown)
+	})
+
+	t.Run("unknown urls have no status", func(t *testing.T) {
+		monitor := &Monitor{}
+
+		if _, ok := monitor.Status("http://a.example"); ok {
+			t.Error("got a status for a url that isn't monitored")
+		}
+		if got := monitor.Statuses(); len(got) != 0 {
+			t.Errorf("got statuses %v want none", got)
+		}
+	})
+
+	t.Run("rejects targets it can't monitor", func(t *testing.T) {
+		checker := (&ScriptedChecker{}).Check
+		cases := map[string]struct {
+			monitor *Monitor
+			targets []Target
+		}{
+			"no checker":    {&Monitor{}, []Target{{"http://a.example", time.Second}}},
+			"no targets":    {&Monitor{Checker: checker}, nil},
+			"zero interval": {&Monitor{Checker: checker}, []Target{{"http://a.example", 0}}},
+			"same url":      {&Monitor{Checker: checker}, []Target{{"http://a.example", time.Second}, {"http://a.example", time.Minute}}},
+		}
+
+		for name, c := range cases {
+			t.Run(name, func(t *testing.T) {
+				if err := c.monitor.Run(context.Background(), c.targets); err == nil {
+					t.Error("expected an error but didn't get one")
+				}
+				if got := c.monitor.Statuses(); len(got) != 0 {
+					t.Errorf("got statuses %v from a monitor that didn't start", got)
+				}
+			})
+		}
+	})
+}
+
+func TestDefaultTicker(t *testing.T) {
+	ticker := NewDefaultTicker(time.Millisecond)
+	defer ticker.Stop()
+
+	select {
+	case <-ticker.Ticks():
+	case <-time.After(time.Second):
+		t.Fatal("DefaultTicker never ticked")
+	}
+}
+
+func runMonitor(m *Monitor, ctx context.Context, targets []Target) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx, targets) }()
+	return done
+}
+
+func waitForChecks(t testing.TB, m *Monitor, url string, checks int) {
+	t.Helper()
+	waitFor(t, func() bool {
+		status, _ := m.Status(url)
+		return status.Checks >= checks
+	})
+}
+
+func waitFor(t testing.TB, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("timed out waiting")
+}
+
+func assertMonitorStopped(t testing.TB, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("didn't expect an error but got one, %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("monitor didn't stop")
+	}
+}
+
+func assertStates(t testing.TB, got []State, want ...State) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got transitions %v want %v", got, want)
+	}
+}
+
+func assertUptime(t testing.TB, status Status, want float64) {
+	t.Helper()
+	if got := status.Uptime(); math.Abs(got-want) > 0.001 {
+		t.Errorf("got uptime %.3f%% for %s want %.3f%%", got, status.URL, want)
+	}
+}
@@ -0,0 +1,99 @@
+package concurrency
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// LogNotifier writes a line to Logger for every event; a nil Logger means
+// the standard logger.
+type LogNotifier struct {
+	Logger *log.Logger
+}
+
+func (l *LogNotifier) Notify(_ context.Context, e Event) error {
+	message := fmt.Sprintf("%s is %s (was %s)", e.URL, e.To, e.From)
+	if e.Result.Err != nil {
+		message += fmt.Sprintf(": %s, %v", e.Result.ErrorKind, e.Result.Err)
+	}
+
+	if l.Logger == nil {
+		log.Print(message)
+		return nil
+	}
+	l.Logger.Print(message)
+	return nil
+}
+
+// WebhookEvent is the JSON body WebhookNotifier posts.
+type WebhookEvent struct {
+	URL        string    `json:"url"`
+	From       State     `json:"from"`
+	To         State     `json:"to"`
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	LatencyMS  int64     `json:"latency_ms"`
+	ErrorKind  ErrorKind `json:"error_kind,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func newWebhookEvent(e Event) WebhookEvent {
+	w := WebhookEvent{
+		URL:        e.URL,
+		From:       e.From,
+		To:         e.To,
+		At:         e.At,
+		StatusCode: e.Result.StatusCode,
+		LatencyMS:  e.Result.Latency.Milliseconds(),
+		ErrorKind:  e.Result.ErrorKind,
+	}
+	if e.Result.Err != nil {
+		w.Error = e.Result.Err.Error()
+	}
+	return w
+}
+
+// WebhookNotifier POSTs every event as a WebhookEvent to URL. Anything but a
+// 2xx reply is an error.
+type WebhookNotifier struct {
+	URL string
+	// Client sends the request; nil means a client with a 10 second timeout.
+	Client *http.Client
+}
+
+var defaultWebhookClient = &http.Client{Timeout: 10 * time.Second}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, e Event) error {
+	body, err := json.Marshal(newWebhookEvent(e))
+	if err != nil {
+		return fmt.Errorf("problem encoding event, %v", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("problem creating webhook request, %v", err)
+	}
+	request.Header.Set("content-type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = defaultWebhookClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("problem calling webhook, %v", err)
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, maxDrain))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package concurrency
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var downEvent = Event{
+	URL:  "http://example.com",
+	From: StateUp,
+	To:   StateDown,
+	At:   monitorStart,
+	Result: CheckResult{
+		URL:        "http://example.com",
+		StatusCode: 503,
+		Latency:    1500 * time.Millisecond,
+		ErrorKind:  ErrorStatus,
+		Err:        errors.New("http://example.com answered 503 Service Unavailable"),
+	},
+}
+
+func TestLogNotifier(t *testing.T) {
+	var buffer bytes.Buffer
+	notifier := &LogNotifier{Logger: log.New(&buffer, "", 0)}
+
+	if err := notifier.Notify(context.Background(), downEvent); err != nil {
+		t.Fatalf("didn't expect an error but got one, %v", err)
+	}
+
+	want := "http://example.com is down (was up): status, http://example.com answered 503 Service Unavailable\n"
+	if buffer.String() != want {
+		t.Errorf("got %q want %q", buffer.String(), want)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+
+	t.Run("posts the event as JSON", func(t *testing.T) {
+		received := make(chan WebhookEvent, 1)
+		hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.Header.Get("content-type") != "application/json" {
+				t.Errorf("got %s with content-type %q", r.Method, r.Header.Get("content-type"))
+			}
+			var e WebhookEvent
+			if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+				t.Errorf("could not decode webhook body, %v", err)
+			}
+			received <- e
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer hook.Close()
+
+		notifier := &WebhookNotifier{URL: hook.URL}
+		if err := notifier.Notify(context.Background(), downEvent); err != nil {
+			t.Fatalf("didn't expect an error but got one, %v", err)
+		}
+
+		got := <-received
+		want := WebhookEvent{
+			URL:        "http://example.com",
+			From:       StateUp,
+			To:         StateDown,
+			At:         monitorStart,
+			StatusCode: 503,
+			LatencyMS:  1500,
+			ErrorKind:  ErrorStatus,
+			Error:      "http://example.com answered 503 Service Unavailable",
+		}
+		if got != want {
+			t.Errorf("got %+v want %+v", got, want)
+		}
+	})
+
+	t.Run("fails when the webhook does", func(t *testing.T) {
+		hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "nope", http.StatusInternalServerError)
+		}))
+		defer hook.Close()
+
+		err := (&WebhookNotifier{URL: hook.URL}).Notify(context.Background(), downEvent)
+
+		if err == nil || !strings.Contains(err.Error(), "500") {
+			t.Errorf("got error %v want one about the 500", err)
+		}
+	})
+
+	t.Run("fails when the webhook can't be reached", func(t *testing.T) {
+		hook := httptest.NewServer(http.NotFoundHandler())
+		hook.Close()
+
+		if err := (&WebhookNotifier{URL: hook.URL}).Notify(context.Background(), downEvent); err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+	})
+}
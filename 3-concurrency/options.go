@@ -0,0 +1,79 @@
+package concurrency
+
+import "time"
+
+// Observer is told how long each check took and how long the whole run
+// took. It is called from the goroutine that called CheckWebsites, one call
+// at a time.
+type Observer interface {
+	CheckDone(url string, took time.Duration)
+	AllDone(took time.Duration)
+}
+
+// Clock tells the CheckWebsites functions what time it is and when a wait
+// is over.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Option changes how the CheckWebsites functions run.
+type Option func(*options)
+
+type options struct {
+	observer        Observer
+	clock           Clock
+	hostEvery       time.Duration
+	hostConcurrency int
+	// queued, if set, is called with a url whose check finds every overall
+	// slot taken and has to wait for one. It lets the tests see the queue.
+	queued func(url string)
+}
+
+func newOptions(opts []Option) options {
+	o := options{clock: realClock{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithObserver reports timings to observer.
+func WithObserver(observer Observer) Option {
+	return func(o *options) {
+		o.observer = observer
+	}
+}
+
+// WithClock times the checks with clock instead of the real time.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithHostRateLimit starts at most one check on each host every every.
+// Checks on different hosts don't hold each other up.
+func WithHostRateLimit(every time.Duration) Option {
+	return func(o *options) {
+		o.hostEvery = every
+	}
+}
+
+// WithHostConcurrency runs at most n checks on each host at a time, within
+// the overall limit.
+func WithHostConcurrency(n int) Option {
+	return func(o *options) {
+		o.hostConcurrency = n
+	}
+}
@@ -0,0 +1,196 @@
+// Package report turns website check results into something that can be
+// handed to people: JSON, CSV or a standalone HTML page.
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Result is one checked url.
+type Result struct {
+	URL        string        `json:"url"`
+	Up         bool          `json:"up"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Latency    time.Duration `json:"-"`
+	FinalURL   string        `json:"final_url,omitempty"`
+	ErrorKind  string        `json:"error_kind,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// LatencyMS is Latency in milliseconds, which is how every format shows it.
+func (r Result) LatencyMS() float64 {
+	return float64(r.Latency) / float64(time.Millisecond)
+}
+
+// Report is a set of results sorted by url.
+type Report struct {
+	Generated time.Time
+	Results   []Result
+}
+
+// New sorts a copy of results into a Report generated at generated.
+func New(results []Result, generated time.Time) Report {
+	sorted := append([]Result{}, results...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].URL < sorted[j].URL })
+	return Report{Generated: generated, Results: sorted}
+}
+
+// Up is how many of the urls were up.
+func (r Report) Up() int {
+	up := 0
+	for _, result := range r.Results {
+		if result.Up {
+			up++
+		}
+	}
+	return up
+}
+
+// Down is how many of the urls were down.
+func (r Report) Down() int {
+	return len(r.Results) - r.Up()
+}
+
+// Format is a way of writing a Report.
+type Format string
+
+const (
+	JSON Format = "json"
+	CSV  Format = "csv"
+	HTML Format = "html"
+)
+
+// Formats lists every Format Write understands.
+var Formats = []Format{JSON, CSV, HTML}
+
+// ParseFormat turns a name like "csv" into its Format.
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats {
+		if strings.EqualFold(name, string(f)) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown report format %q, use json, csv or html", name)
+}
+
+// Write writes the report to w in format.
+func (r Report) Write(w io.Writer, format Format) error {
+	switch format {
+	case JSON:
+		return r.WriteJSON(w)
+	case CSV:
+		return r.WriteCSV(w)
+	case HTML:
+		return r.WriteHTML(w)
+	}
+	return fmt.Errorf("unknown report format %q", format)
+}
+
+type jsonResult struct {
+	Result
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+type jsonReport struct {
+	Generated time.Time    `json:"generated"`
+	Total     int          `json:"total"`
+	Up        int          `json:"up"`
+	Down      int          `json:"down"`
+	Results   []jsonResult `json:"results"`
+}
+
+func (r Report) WriteJSON(w io.Writer) error {
+	out := jsonReport{
+		Generated: r.Generated,
+		Total:     len(r.Results),
+		Up:        r.Up(),
+		Down:      r.Down(),
+		Results:   []jsonResult{},
+	}
+	for _, result := range r.Results {
+		out.Results = append(out.Results, jsonResult{result, result.LatencyMS()})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+var csvHeader = []string{"url", "up", "status_code", "latency_ms", "final_url", "error_kind", "error"}
+
+func (r Report) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write(csvHeader)
+	for _, result := range r.Results {
+		status := ""
+		if result.StatusCode != 0 {
+			status = strconv.Itoa(result.StatusCode)
+		}
+		out.Write([]string{
+			result.URL,
+			strconv.FormatBool(result.Up),
+			status,
+			strconv.FormatFloat(result.LatencyMS(), 'f', 1, 64),
+			result.FinalURL,
+			result.ErrorKind,
+			result.Error,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteHTML writes a page with everything it needs inline, so it can be
+// mailed or opened straight from disk.
+func (r Report) WriteHTML(w io.Writer) error {
+	return htmlReport.Execute(w, r)
+}
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ms": func(r Result) string { return strconv.FormatFloat(r.LatencyMS(), 'f', 1, 64) + " ms" },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Website check {{.Generated.Format "2006-01-02 15:04:05 MST"}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { padding: 0.4em 0.8em; border-bottom: 1px solid #ddd; text-align: left; }
+td.number { text-align: right; }
+.badge { display: inline-block; padding: 0.1em 0.6em; border-radius: 0.8em; color: #fff; font-weight: bold; font-size: 0.85em; }
+.up { background: #2e7d32; }
+.down { background: #c62828; }
+</style>
+</head>
+<body>
+<h1>Website check</h1>
+<p>Checked {{len .Results}} sites at {{.Generated.Format "2006-01-02 15:04:05 MST"}}: {{.Up}} up, {{.Down}} down.</p>
+<table>
+<thead>
+<tr><th>Site</th><th>Status</th><th>Code</th><th>Latency</th><th>Ended up at</th><th>Problem</th></tr>
+</thead>
+<tbody>
+{{- range .Results}}
+<tr>
+<td>{{.URL}}</td>
+<td>{{if .Up}}<span class="badge up">UP</span>{{else}}<span class="badge down">DOWN</span>{{end}}</td>
+<td class="number">{{if .StatusCode}}{{.StatusCode}}{{end}}</td>
+<td class="number">{{ms .}}</td>
+<td>{{.FinalURL}}</td>
+<td>{{if .ErrorKind}}{{.ErrorKind}}: {{.Error}}{{end}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
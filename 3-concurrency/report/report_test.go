@@ -0,0 +1,133 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var generated = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+var results = []Result{
+	{URL: "https://z.example", Up: true, StatusCode: 200, Latency: 120 * time.Millisecond, FinalURL: "https://z.example/home"},
+	{URL: "https://a.example", ErrorKind: "timeout", Error: "context deadline exceeded", Latency: 10 * time.Second},
+	{URL: "https://m.example", StatusCode: 503, ErrorKind: "status", Error: `<script>alert("hi")</script>`, Latency: 1500 * time.Microsecond},
+}
+
+func TestNew(t *testing.T) {
+	r := New(results, generated)
+
+	var urls []string
+	for _, result := range r.Results {
+		urls = append(urls, result.URL)
+	}
+	if got, want := strings.Join(urls, " "), "https://a.example https://m.example https://z.example"; got != want {
+		t.Errorf("got urls in order %q want %q", got, want)
+	}
+	if results[0].URL != "https://z.example" {
+		t.Error("New sorted the caller's slice")
+	}
+	if r.Up() != 1 || r.Down() != 2 {
+		t.Errorf("got %d up and %d down want 1 and 2", r.Up(), r.Down())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buffer bytes.Buffer
+	assertNoError(t, New(results, generated).Write(&buffer, JSON))
+
+	var got struct {
+		Generated time.Time
+		Total     int
+		Up        int
+		Down      int
+		Results   []map[string]any
+	}
+	assertNoError(t, json.Unmarshal(buffer.Bytes(), &got))
+
+	if !got.Generated.Equal(generated) || got.Total != 3 || got.Up != 1 || got.Down != 2 {
+		t.Errorf("got summary %+v", got)
+	}
+	first := got.Results[0]
+	if first["url"] != "https://a.example" || first["latency_ms"] != 10000.0 || first["error_kind"] != "timeout" || first["up"] != false {
+		t.Errorf("got first result %v", first)
+	}
+	if _, ok := first["status_code"]; ok {
+		t.Errorf("got a status code for a site that never answered, %v", first)
+	}
+}
+
+func TestWriteJSONEmpty(t *testing.T) {
+	var buffer bytes.Buffer
+	assertNoError(t, New(nil, generated).WriteJSON(&buffer))
+
+	if !strings.Contains(buffer.String(), `"results": []`) {
+		t.Errorf("got %s want an empty results list", buffer.String())
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buffer bytes.Buffer
+	assertNoError(t, New(results, generated).Write(&buffer, CSV))
+
+	want := `url,up,status_code,latency_ms,final_url,error_kind,error
+https://a.example,false,,10000.0,,timeout,context deadline exceeded
+https://m.example,false,503,1.5,,status,"<script>alert(""hi"")</script>"
+https://z.example,true,200,120.0,https://z.example/home,,
+`
+	if buffer.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buffer.String(), want)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buffer bytes.Buffer
+	assertNoError(t, New(results, generated).Write(&buffer, HTML))
+	got := buffer.String()
+
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"<style>",
+		"3 sites at 2024-03-01 12:00:00 UTC: 1 up, 2 down",
+		`<span class="badge up">UP</span>`,
+		`<span class="badge down">DOWN</span>`,
+		"120.0 ms",
+		"&lt;script&gt;",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("page is missing %q", want)
+		}
+	}
+	if strings.Contains(got, "<script>") {
+		t.Error("page has an unescaped script tag")
+	}
+	if strings.Contains(got, "<link") || strings.Contains(got, "src=") {
+		t.Error("page loads something from elsewhere")
+	}
+	if strings.Index(got, "https://a.example") > strings.Index(got, "https://z.example") {
+		t.Error("page isn't sorted by url")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, name := range []string{"json", "CSV", "Html"} {
+		if _, err := ParseFormat(name); err != nil {
+			t.Errorf("ParseFormat(%q) failed, %v", name, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected an error for xml but didn't get one")
+	}
+	if err := New(results, generated).Write(&bytes.Buffer{}, "xml"); err == nil {
+		t.Error("expected an error writing xml but didn't get one")
+	}
+}
+
+func assertNoError(t testing.TB, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("didn't expect an error but got one, %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package concurrency
+
+import (
+	"net"
+	"strings"
+)
+
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+// normalizeURL puts rawURL in the one form CheckWebsites checks it in: a
+// lower case scheme and host, no default port, no trailing slash and no
+// fragment. Anything that isn't an absolute http or https url is an error.
+func normalizeURL(rawURL string) (string, error) {
+	u, err := parseHTTPURL(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if host, port, err := net.SplitHostPort(u.Host); err == nil && (port == "" || port == defaultPorts[u.Scheme]) {
+		u.Host = host
+		if strings.Contains(host, ":") {
+			// an IPv6 address keeps its brackets
+			u.Host = "[" + host + "]"
+		}
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String(), nil
+}
+
+// checkPlan is the urls to check once each, and which of the urls asked for
+// each one answers.
+type checkPlan struct {
+	unique []string
+	asked  map[string][]string
+	// invalid holds the urls that can't be checked and why.
+	invalid map[string]error
+}
+
+func planChecks(urls []string) checkPlan {
+	plan := checkPlan{asked: map[string][]string{}, invalid: map[string]error{}}
+	for _, raw := range urls {
+		normalized, err := normalizeURL(raw)
+		if err != nil {
+			plan.invalid[raw] = err
+			continue
+		}
+		if _, seen := plan.asked[normalized]; !seen {
+			plan.unique = append(plan.unique, normalized)
+		}
+		plan.asked[normalized] = appendNew(plan.asked[normalized], raw)
+	}
+	return plan
+}
+
+func appendNew(urls []string, u string) []string {
+	for _, existing := range urls {
+		if existing == u {
+			return urls
+		}
+	}
+	return append(urls, u)
+}
@@ -0,0 +1,53 @@
+package concurrency
+
+import "testing"
+
+func TestNormalizeURL(t *testing.T) {
+	cases := []struct {
+		url  string
+		want string
+	}{
+		{"http://example.com", "http://example.com"},
+		{"  http://example.com  ", "http://example.com"},
+		{"HTTP://Example.COM", "http://example.com"},
+		{"http://example.com/", "http://example.com"},
+		{"http://example.com/a/b/", "http://example.com/a/b"},
+		{"http://example.com/A/B", "http://example.com/A/B"},
+		{"http://example.com:80", "http://example.com"},
+		{"https://example.com:443/", "https://example.com"},
+		{"http://example.com:443", "http://example.com:443"},
+		{"https://example.com:8443", "https://example.com:8443"},
+		{"http://example.com:/", "http://example.com"},
+		{"http://[::1]:80/", "http://[::1]"},
+		{"http://[::1]:8080", "http://[::1]:8080"},
+		{"http://example.com/search?q=Go#results", "http://example.com/search?q=Go"},
+		{"http://example.com/a%2Fb/", "http://example.com/a%2Fb"},
+	}
+
+	for _, c := range cases {
+		got, err := normalizeURL(c.url)
+		if err != nil {
+			t.Errorf("normalizeURL(%q) failed, %v", c.url, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("normalizeURL(%q) = %q want %q", c.url, got, c.want)
+		}
+	}
+}
+
+func TestNormalizeURLRejects(t *testing.T) {
+	for _, url := range []string{
+		"waat://furhurterwe.geds",
+		"ftp://example.com",
+		"example.com",
+		"/just/a/path",
+		"http://",
+		"http://exa mple.com",
+		"",
+	} {
+		if got, err := normalizeURL(url); err == nil {
+			t.Errorf("normalizeURL(%q) = %q want an error", url, got)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+// auth.go
+package poker
+
+import (
+	"bytes"
+	"container/heap"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnauthenticated = errors.New("request is not authenticated")
+
+// Authenticator decides whether a request may change the league. It returns
+// nil for a trusted request and an error saying why otherwise.
+type Authenticator interface {
+	Authenticate(r *http.Request) error
+}
+
+// RequireAuth lets reads through untouched and sends every write through
+// auth first. Writes are anything but GET, HEAD and OPTIONS, plus the /ws
+// websocket, since a game played over it records its winner. Rejected
+// requests get a 401 with a JSON error.
+func RequireAuth(next http.Handler, auth Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isWrite(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := auth.Authenticate(r); err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="poker"`)
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isWrite(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return r.URL.Path == "/ws"
+	}
+	return true
+}
+
+// AnyAuthenticator accepts a request if any of auths does.
+type AnyAuthenticator []Authenticator
+
+func (a AnyAuthenticator) Authenticate(r *http.Request) error {
+	var errs []error
+	for _, auth := range a {
+		err := auth.Authenticate(r)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return ErrUnauthenticated
+	}
+	return errors.Join(errs...)
+}
+
+const apiKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator accepts requests carrying one of its keys, either in
+// the X-API-Key header or as a bearer token. Browsers can't set headers on a
+// websocket, so the handshake may pass the key as the api_key query
+// parameter instead.
+type APIKeyAuthenticator struct {
+	// Keys maps each client's name to its key.
+	Keys map[string]string
+}
+
+func (a APIKeyAuthenticator) Authenticate(r *http.Request) error {
+	key := r.Header.Get(apiKeyHeader)
+	if key == "" {
+		key, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if key == "" && r.URL.Path == "/ws" {
+		key = r.URL.Query().Get("api_key")
+	}
+	if key == "" {
+		return fmt.Errorf("%w: no API key", ErrUnauthenticated)
+	}
+
+	// compare against every key so the time taken doesn't give one away
+	matched := 0
+	for _, known := range a.Keys {
+		matched |= subtle.ConstantTimeCompare([]byte(key), []byte(known))
+	}
+	if matched != 1 {
+		return fmt.Errorf("%w: unknown API key", ErrUnauthenticated)
+	}
+	return nil
+}
+
+const (
+	clientIDHeader  = "X-Client-ID"
+	timestampHeader = "X-Timestamp"
+	nonceHeader     = "X-Nonce"
+	signatureHeader = "X-Signature"
+)
+
+// maxNonceLength keeps clients from filling the seen nonces with huge ones.
+const maxNonceLength = 64
+
+// maxSignedBody caps how much of a signed request is read to check it.
+const maxSignedBody = 1 << 20
+
+// HMACAuthenticator accepts requests signed with a client's shared secret,
+// see SignRequest. Signatures older or newer than MaxSkew are refused, and
+// each signed request carries a nonce that is only accepted once while its
+// timestamp is within MaxSkew, so a captured request can't be replayed.
+// The nonces seen are kept in memory, so use one HMACAuthenticator per
+// server and don't copy it once it is in use.
+type HMACAuthenticator struct {
+	// Secrets maps each client ID to its shared secret.
+	Secrets map[string]string
+	MaxSkew time.Duration
+	Clock   Clock
+
+	mu sync.Mutex
+	// seen holds each client's recent nonces, and expiring orders them by
+	// when they can be forgotten so only the expired ones are looked at.
+	seen     map[string]struct{}
+	expiring nonceQueue
+}
+
+const DefaultMaxSkew = 5 * time.Minute
+
+func (a *HMACAuthenticator) Authenticate(r *http.Request) error {
+	clientID := r.Header.Get(clientIDHeader)
+	secret, ok := a.Secrets[clientID]
+	if clientID == "" || !ok {
+		return fmt.Errorf("%w: unknown client %q", ErrUnauthenticated, clientID)
+	}
+
+	unix, err := strconv.ParseInt(r.Header.Get(timestampHeader), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad %s header", ErrUnauthenticated, timestampHeader)
+	}
+	maxSkew := a.MaxSkew
+	if maxSkew == 0 {
+		maxSkew = DefaultMaxSkew
+	}
+	now := a.Clock.now()
+	skew := now.Sub(time.Unix(unix, 0))
+	if skew > maxSkew || skew < -maxSkew {
+		return fmt.Errorf("%w: signature timestamp is too far from now", ErrUnauthenticated)
+	}
+
+	nonce := r.Header.Get(nonceHeader)
+	if nonce == "" || len(nonce) > maxNonceLength {
+		return fmt.Errorf("%w: bad %s header", ErrUnauthenticated, nonceHeader)
+	}
+
+	got, err := hex.DecodeString(r.Header.Get(signatureHeader))
+	if err != nil {
+		return fmt.Errorf("%w: bad %s header", ErrUnauthenticated, signatureHeader)
+	}
+
+	want, err := signature(r, []byte(secret), unix, nonce)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	if !hmac.Equal(got, want) {
+		return fmt.Errorf("%w: signature does not match", ErrUnauthenticated)
+	}
+
+	// past this the timestamp is refused anyway, so the nonce can go
+	forget := time.Unix(unix, 0).Add(maxSkew)
+	if !a.firstUse(clientID+"\n"+nonce, now, forget) {
+		return fmt.Errorf("%w: nonce has already been used", ErrUnauthenticated)
+	}
+	return nil
+}
+
+// firstUse records key until forget and reports whether it is new. Keys
+// whose time has passed are dropped on the way.
+func (a *HMACAuthenticator) firstUse(key string, now, forget time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for len(a.expiring) > 0 && now.After(a.expiring[0].forget) {
+		delete(a.seen, heap.Pop(&a.expiring).(seenNonce).key)
+	}
+	if _, ok := a.seen[key]; ok {
+		return false
+	}
+	if a.seen == nil {
+		a.seen = map[string]struct{}{}
+	}
+	a.seen[key] = struct{}{}
+	heap.Push(&a.expiring, seenNonce{key, forget})
+	return true
+}
+
+type seenNonce struct {
+	key    string
+	forget time.Time
+}
+
+// nonceQueue is a container/heap of nonces, the first to be forgotten on
+// top. Timestamps can arrive out of order within MaxSkew, so a plain queue
+// wouldn't do.
+type nonceQueue []seenNonce
+
+func (q nonceQueue) Len() int           { return len(q) }
+func (q nonceQueue) Less(i, j int) bool { return q[i].forget.Before(q[j].forget) }
+func (q nonceQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *nonceQueue) Push(x any)        { *q = append(*q, x.(seenNonce)) }
+
+func (q *nonceQueue) Pop() any {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
+
+// SignRequest adds the headers HMACAuthenticator checks, with a fresh
+// random nonce, so every signed request can only be sent once. The
+// signature is an HMAC-SHA256 over the method, the path with its query, the
+// unix timestamp, the nonce and the body, separated by newlines.
+func SignRequest(r *http.Request, clientID, secret string, now time.Time) error {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return fmt.Errorf("problem making a nonce, %v", err)
+	}
+	nonce := hex.EncodeToString(random)
+
+	unix := now.Unix()
+	sig, err := signature(r, []byte(secret), unix, nonce)
+	if err != nil {
+		return err
+	}
+	r.Header.Set(clientIDHeader, clientID)
+	r.Header.Set(timestampHeader, strconv.FormatInt(unix, 10))
+	r.Header.Set(nonceHeader, nonce)
+	r.Header.Set(signatureHeader, hex.EncodeToString(sig))
+	return nil
+}
+
+// signature reads the body to sign it and puts it back for the next reader.
+func signature(r *http.Request, secret []byte, unix int64, nonce string) ([]byte, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxSignedBody+1))
+		r.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("problem reading body, %v", err)
+		}
+		if len(body) > maxSignedBody {
+			return nil, errors.New("body is too large to sign")
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%d\n%s\n", r.Method, r.URL.RequestURI(), unix, nonce)
+	mac.Write(body)
+	return mac.Sum(nil), nil
+}
+
+// AuthConfig is the file behind the webserver's -auth-config flag, e.g.
+//
+//	{
+//		"APIKeys": {"scoreboard": "a-long-random-key"},
+//		"HMACSecrets": {"reporting": "another-long-secret"}
+//	}
+type AuthConfig struct {
+	APIKeys     map[string]string
+	HMACSecrets map[string]string
+}
+
+func LoadAuthConfig(path string) (AuthConfig, error) {
+	var config AuthConfig
+
+	f, err := os.Open(path)
+	if err != nil {
+		return config, fmt.Errorf("problem opening %s %v", path, err)
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&config); err != nil {
+		return config, fmt.Errorf("problem parsing auth config %s, %v", path, err)
+	}
+	if len(config.APIKeys) == 0 && len(config.HMACSecrets) == 0 {
+		return config, fmt.Errorf("auth config %s has no API keys or HMAC secrets", path)
+	}
+	for name, key := range config.APIKeys {
+		if key == "" {
+			return config, fmt.Errorf("auth config %s has an empty API key for %q", path, name)
+		}
+	}
+	for name, secret := range config.HMACSecrets {
+		if secret == "" {
+			return config, fmt.Errorf("auth config %s has an empty HMAC secret for %q", path, name)
+		}
+	}
+	return config, nil
+}
+
+// Authenticator accepts requests that pass any of the configured schemes.
+func (c AuthConfig) Authenticator() Authenticator {
+	var auths AnyAuthenticator
+	if len(c.APIKeys) > 0 {
+		auths = append(auths, APIKeyAuthenticator{Keys: c.APIKeys})
+	}
+	if len(c.HMACSecrets) > 0 {
+		auths = append(auths, &HMACAuthenticator{Secrets: c.HMACSecrets})
+	}
+	return auths
+}
@@ -0,0 +1,340 @@
+// auth_test.go
+package poker
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAPIKey = "correct-horse-battery-staple"
+const testSecret = "a-shared-secret"
+
+var signingTime = time.Date(2026, time.October, 9, 20, 0, 0, 0, time.UTC)
+
+func newAuthedServer(store PlayerStore) http.Handler {
+	auth := AnyAuthenticator{
+		APIKeyAuthenticator{Keys: map[string]string{"scoreboard": testAPIKey}},
+		&HMACAuthenticator{
+			Secrets: map[string]string{"reporting": testSecret},
+			Clock:   func() time.Time { return signingTime },
+		},
+	}
+	return RequireAuth(NewPlayerServer(store, dummyGame, dummyRatings), auth)
+}
+
+func TestRequireAuth(t *testing.T) {
+
+	t.Run("score lookups stay public", func(t *testing.T) {
+		store := StubPlayerStore{scores: map[string]int{"Pepper": 3}}
+		server := newAuthedServer(&store)
+
+		response := newScoreResponse(server, "Pepper")
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertResponseBody(t, response.Body.String(), "3")
+	})
+
+	t.Run("league stays public", func(t *testing.T) {
+		server := newAuthedServer(&StubPlayerStore{})
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newLeagueRequest())
+
+		assertStatus(t, response.Code, http.StatusOK)
+	})
+
+	t.Run("anonymous wins are refused with 401", func(t *testing.T) {
+		store := StubPlayerStore{}
+		server := newAuthedServer(&store)
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newPostWinRequest("Pepper"))
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+		assertErrorResponse(t, response, http.StatusUnauthorized)
+		if response.Header().Get("WWW-Authenticate") == "" {
+			t.Error("401 without a WWW-Authenticate header")
+		}
+		assertNoWins(t, &store)
+	})
+
+	t.Run("the websocket counts as a write", func(t *testing.T) {
+		server := newAuthedServer(&StubPlayerStore{})
+
+		request, _ := http.NewRequest(http.MethodGet, "/ws", nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+	})
+
+	t.Run("an API key in the header lets a win through", func(t *testing.T) {
+		store := StubPlayerStore{}
+		server := newAuthedServer(&store)
+
+		request := newPostWinRequest("Pepper")
+		request.Header.Set("X-API-Key", testAPIKey)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusAccepted)
+		assertPlayerWin(t, &store, "Pepper")
+	})
+
+	t.Run("an API key as a bearer token lets a win through", func(t *testing.T) {
+		store := StubPlayerStore{}
+		server := newAuthedServer(&store)
+
+		request := newPostWinRequest("Pepper")
+		request.Header.Set("Authorization", "Bearer "+testAPIKey)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusAccepted)
+	})
+
+	t.Run("a wrong API key is refused", func(t *testing.T) {
+		store := StubPlayerStore{}
+		server := newAuthedServer(&store)
+
+		request := newPostWinRequest("Pepper")
+		request.Header.Set("X-API-Key", "guess")
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+		assertNoWins(t, &store)
+	})
+
+	t.Run("an API key in the query only counts for the websocket", func(t *testing.T) {
+		store := StubPlayerStore{}
+		server := newAuthedServer(&store)
+
+		request, _ := http.NewRequest(http.MethodPost, "/players/Pepper?api_key="+testAPIKey, nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+	})
+
+	t.Run("the websocket handshake can carry the API key in the query", func(t *testing.T) {
+		server := newAuthedServer(&StubPlayerStore{})
+
+		request, _ := http.NewRequest(http.MethodGet, "/ws?api_key="+testAPIKey, nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		// not a real websocket handshake, so the upgrader turns it away
+		assertStatus(t, response.Code, http.StatusBadRequest)
+	})
+
+	t.Run("a signed match is let through with its body intact", func(t *testing.T) {
+		store := StubPlayerStore{}
+		server := newAuthedServer(&store)
+
+		request := newSignedMatchRequest(t, `{"Winner": "Pepper", "Losers": ["Cleo"]}`, testSecret, signingTime)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusAccepted)
+		assertPlayerWin(t, &store, "Pepper")
+	})
+
+	t.Run("a signed request with a tampered body is refused", func(t *testing.T) {
+		store := StubPlayerStore{}
+		server := newAuthedServer(&store)
+
+		request := newSignedMatchRequest(t, `{"Winner": "Pepper", "Losers": ["Cleo"]}`, testSecret, signingTime)
+		request.Body = io.NopCloser(strings.NewReader(`{"Winner": "Cleo", "Losers": ["Pepper"]}`))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+		assertNoWins(t, &store)
+	})
+
+	t.Run("a request signed with the wrong secret is refused", func(t *testing.T) {
+		store := StubPlayerStore{}
+		server := newAuthedServer(&store)
+
+		request := newSignedMatchRequest(t, `{"Winner": "Pepper", "Losers": ["Cleo"]}`, "not-the-secret", signingTime)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+	})
+
+	t.Run("a replayed signed request is refused", func(t *testing.T) {
+		store := StubPlayerStore{}
+		server := newAuthedServer(&store)
+		body := `{"Winner": "Pepper", "Losers": ["Cleo"]}`
+
+		request := newSignedMatchRequest(t, body, testSecret, signingTime)
+		server.ServeHTTP(httptest.NewRecorder(), request)
+
+		replay, _ := http.NewRequest(http.MethodPost, "/matches", strings.NewReader(body))
+		replay.Header = request.Header.Clone()
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, replay)
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+		if len(store.winCalls) != 1 {
+			t.Errorf("got %d wins recorded want 1", len(store.winCalls))
+		}
+	})
+
+	t.Run("a signed request without a nonce is refused", func(t *testing.T) {
+		store := StubPlayerStore{}
+		server := newAuthedServer(&store)
+
+		request := newSignedMatchRequest(t, `{"Winner": "Pepper", "Losers": ["Cleo"]}`, testSecret, signingTime)
+		request.Header.Del("X-Nonce")
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+		assertNoWins(t, &store)
+	})
+
+	t.Run("an old signature is refused", func(t *testing.T) {
+		store := StubPlayerStore{}
+		server := newAuthedServer(&store)
+
+		request := newSignedMatchRequest(t, `{"Winner": "Pepper", "Losers": ["Cleo"]}`, testSecret, signingTime.Add(-time.Hour))
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusUnauthorized)
+	})
+}
+
+func TestAuthenticators(t *testing.T) {
+
+	t.Run("errors wrap ErrUnauthenticated", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/players/Pepper", nil)
+
+		err := AnyAuthenticator{APIKeyAuthenticator{}, &HMACAuthenticator{}}.Authenticate(request)
+
+		if !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("got %v want it to wrap %v", err, ErrUnauthenticated)
+		}
+	})
+
+	t.Run("HMAC nonces are forgotten once their signature is too old", func(t *testing.T) {
+		now := signingTime
+		auth := &HMACAuthenticator{
+			Secrets: map[string]string{"reporting": testSecret},
+			Clock:   func() time.Time { return now },
+		}
+
+		assertNoError(t, auth.Authenticate(newSignedMatchRequest(t, "{}", testSecret, signingTime)))
+		now = now.Add(DefaultMaxSkew + time.Second)
+		assertNoError(t, auth.Authenticate(newSignedMatchRequest(t, "{}", testSecret, now)))
+
+		if len(auth.seen) != 1 {
+			t.Errorf("got %d nonces remembered want 1", len(auth.seen))
+		}
+	})
+
+	t.Run("HMAC nonces are forgotten in the order their signatures get too old", func(t *testing.T) {
+		now := signingTime
+		auth := &HMACAuthenticator{
+			Secrets: map[string]string{"reporting": testSecret},
+			Clock:   func() time.Time { return now },
+		}
+
+		late := newSignedMatchRequest(t, "{}", testSecret, signingTime.Add(4*time.Minute))
+		assertNoError(t, auth.Authenticate(late))
+		assertNoError(t, auth.Authenticate(newSignedMatchRequest(t, "{}", testSecret, signingTime.Add(-4*time.Minute))))
+		now = now.Add(2 * time.Minute)
+		assertNoError(t, auth.Authenticate(newSignedMatchRequest(t, "{}", testSecret, now)))
+
+		if len(auth.seen) != 2 || len(auth.expiring) != 2 {
+			t.Errorf("got %d nonces remembered and %d expiring want 2 of each", len(auth.seen), len(auth.expiring))
+		}
+		replay, _ := http.NewRequest(http.MethodPost, "/matches", strings.NewReader("{}"))
+		replay.Header = late.Header.Clone()
+		if err := auth.Authenticate(replay); err == nil {
+			t.Error("replay of a nonce that is still fresh was let through")
+		}
+	})
+
+	t.Run("no authenticators let nothing through", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/players/Pepper", nil)
+
+		if err := (AnyAuthenticator{}).Authenticate(request); err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+	})
+}
+
+func TestLoadAuthConfig(t *testing.T) {
+
+	t.Run("reads keys and secrets", func(t *testing.T) {
+		path := writeAuthConfig(t, `{"APIKeys": {"scoreboard": "k1"}, "HMACSecrets": {"reporting": "s1"}}`)
+
+		config, err := LoadAuthConfig(path)
+		assertNoError(t, err)
+
+		if config.APIKeys["scoreboard"] != "k1" || config.HMACSecrets["reporting"] != "s1" {
+			t.Errorf("got config %+v", config)
+		}
+		if got := len(config.Authenticator().(AnyAuthenticator)); got != 2 {
+			t.Errorf("got %d authenticators want 2", got)
+		}
+	})
+
+	bad := map[string]string{
+		"not json":        `APIKeys = k1`,
+		"nothing in it":   `{}`,
+		"an empty key":    `{"APIKeys": {"scoreboard": ""}}`,
+		"an empty secret": `{"HMACSecrets": {"reporting": ""}}`,
+	}
+	for name, contents := range bad {
+		t.Run("rejects "+name, func(t *testing.T) {
+			_, err := LoadAuthConfig(writeAuthConfig(t, contents))
+			if err == nil {
+				t.Error("expected an error but didn't get one")
+			}
+		})
+	}
+
+	t.Run("rejects a missing file", func(t *testing.T) {
+		_, err := LoadAuthConfig(filepath.Join(t.TempDir(), "missing.json"))
+		if err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+	})
+}
+
+func newSignedMatchRequest(t testing.TB, body, secret string, at time.Time) *http.Request {
+	t.Helper()
+	request, _ := http.NewRequest(http.MethodPost, "/matches", strings.NewReader(body))
+	if err := SignRequest(request, "reporting", secret, at); err != nil {
+		t.Fatalf("could not sign request %v", err)
+	}
+	return request
+}
+
+func writeAuthConfig(t testing.TB, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "auth.json")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("could not write auth config %v", err)
+	}
+	return path
+}
+
+func assertNoWins(t testing.TB, store *StubPlayerStore) {
+	t.Helper()
+	if len(store.winCalls) != 0 {
+		t.Errorf("got wins %v for an unauthenticated request", store.winCalls)
+	}
+}
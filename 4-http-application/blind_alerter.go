@@ -0,0 +1,29 @@
+// blind_alerter.go
+package poker
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// BlindAlerter schedules a message announcing the new blind amount. The
+// returned func cancels the alert if it hasn't gone out yet.
+type BlindAlerter interface {
+	ScheduleAlertAt(duration time.Duration, amount int, to io.Writer) (cancel func())
+}
+
+// BlindAlerterFunc allows you to implement BlindAlerter with a function.
+type BlindAlerterFunc func(duration time.Duration, amount int, to io.Writer) (cancel func())
+
+func (a BlindAlerterFunc) ScheduleAlertAt(duration time.Duration, amount int, to io.Writer) func() {
+	return a(duration, amount, to)
+}
+
+// Alerter writes the new blind to "to" once duration has passed.
+func Alerter(duration time.Duration, amount int, to io.Writer) func() {
+	timer := time.AfterFunc(duration, func() {
+		fmt.Fprintf(to, "Blind is now %d\n", amount)
+	})
+	return func() { timer.Stop() }
+}
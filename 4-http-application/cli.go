@@ -0,0 +1,76 @@
+// cli.go
+package poker
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const winSuffix = " wins"
+
+const PlayerPrompt = "Please enter the number of players: "
+const BadPlayerInputErrMsg = "Bad value received for number of players, please try again with a number"
+const BadWinnerInputMsg = "invalid winner input, expect format of 'PlayerName wins'"
+const WinNotSavedMsg = "could not save the win"
+
+// CLI runs a game of poker at the terminal: it asks how many players there
+// are, starts the game and finishes it on the first "{Name} wins" line.
+type CLI struct {
+	in   *bufio.Scanner
+	out  io.Writer
+	game Game
+}
+
+func NewCLI(in io.Reader, out io.Writer, game Game) *CLI {
+	return &CLI{
+		in:   bufio.NewScanner(in),
+		out:  out,
+		game: game,
+	}
+}
+
+func (cli *CLI) PlayPoker() {
+	fmt.Fprint(cli.out, PlayerPrompt)
+
+	numberOfPlayers, err := strconv.Atoi(strings.TrimSpace(cli.readLine()))
+	if err != nil || !validNumberOfPlayers(numberOfPlayers) {
+		fmt.Fprint(cli.out, BadPlayerInputErrMsg)
+		return
+	}
+
+	stop := cli.game.Start(numberOfPlayers, cli.out)
+	defer stop()
+
+	for cli.in.Scan() {
+		winner, ok := extractWinner(cli.in.Text())
+		if !ok {
+			fmt.Fprintln(cli.out, BadWinnerInputMsg)
+			continue
+		}
+		if err := validatePlayerName(winner); err != nil {
+			fmt.Fprintln(cli.out, err)
+			continue
+		}
+		if err := cli.game.Finish(winner); err != nil {
+			fmt.Fprintf(cli.out, "%s, %v\n", WinNotSavedMsg, err)
+		}
+		return
+	}
+}
+
+func extractWinner(userInput string) (string, bool) {
+	userInput = strings.TrimSpace(userInput)
+	if !strings.HasSuffix(userInput, winSuffix) {
+		return "", false
+	}
+	winner := strings.TrimSpace(strings.TrimSuffix(userInput, winSuffix))
+	return winner, winner != ""
+}
+
+func (cli *CLI) readLine() string {
+	cli.in.Scan()
+	return cli.in.Text()
+}
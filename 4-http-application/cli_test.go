@@ -0,0 +1,244 @@
+// cli_test.go
+package poker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// GameSpy records how it was driven. The websocket tests drive it from the
+// server's goroutine, so every field is guarded by mu.
+type GameSpy struct {
+	mu sync.Mutex
+
+	StartCalled bool
+	StartedWith int
+	BlindAlert  []byte
+	StopCalled  bool
+
+	FinishCalled bool
+	FinishedWith string
+	FinishErr    error
+}
+
+func (g *GameSpy) Start(numberOfPlayers int, out io.Writer) func() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.StartCalled = true
+	g.StartedWith = numberOfPlayers
+	out.Write(g.BlindAlert)
+	return func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.StopCalled = true
+	}
+}
+
+func (g *GameSpy) Finish(winner string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.FinishCalled = true
+	g.FinishedWith = winner
+	return g.FinishErr
+}
+
+var dummyGame = &GameSpy{}
+
+func userSends(messages ...string) io.Reader {
+	return strings.NewReader(strings.Join(messages, "\n"))
+}
+
+func TestCLI(t *testing.T) {
+
+	t.Run("start game with 3 players and finish game with 'Chris' as winner", func(t *testing.T) {
+		game := &GameSpy{}
+		stdout := &bytes.Buffer{}
+
+		in := userSends("3", "Chris wins")
+		cli := NewCLI(in, stdout, game)
+
+		cli.PlayPoker()
+
+		assertMessagesSentToUser(t, stdout, PlayerPrompt)
+		assertGameStartedWith(t, game, 3)
+		assertFinishCalledWith(t, game, "Chris")
+		assertGameStopped(t, game)
+	})
+
+	t.Run("start game with 8 players and record 'Cleo' as winner", func(t *testing.T) {
+		game := &GameSpy{}
+
+		in := userSends("8", "Cleo wins")
+		cli := NewCLI(in, io.Discard, game)
+
+		cli.PlayPoker()
+
+		assertGameStartedWith(t, game, 8)
+		assertFinishCalledWith(t, game, "Cleo")
+	})
+
+	t.Run("it prints an error when a non numeric value is entered and does not start the game", func(t *testing.T) {
+		game := &GameSpy{}
+
+		stdout := &bytes.Buffer{}
+		in := userSends("pies")
+
+		cli := NewCLI(in, stdout, game)
+		cli.PlayPoker()
+
+		assertGameNotStarted(t, game)
+		assertMessagesSentToUser(t, stdout, PlayerPrompt, BadPlayerInputErrMsg)
+	})
+
+	t.Run("it rejects more than MaxPlayers players", func(t *testing.T) {
+		game := &GameSpy{}
+
+		stdout := &bytes.Buffer{}
+		in := userSends(fmt.Sprint(MaxPlayers+1), "Cleo wins")
+
+		cli := NewCLI(in, stdout, game)
+		cli.PlayPoker()
+
+		assertGameNotStarted(t, game)
+		assertMessagesSentToUser(t, stdout, PlayerPrompt, BadPlayerInputErrMsg)
+	})
+
+	t.Run("it complains about lines without a winner until one is given", func(t *testing.T) {
+		game := &GameSpy{}
+
+		stdout := &bytes.Buffer{}
+		in := userSends("3", "Lloyd is a killer", "Cleo wins")
+
+		cli := NewCLI(in, stdout, game)
+		cli.PlayPoker()
+
+		assertMessagesSentToUser(t, stdout, PlayerPrompt, BadWinnerInputMsg+"\n")
+		assertFinishCalledWith(t, game, "Cleo")
+	})
+
+	t.Run("it asks again for a winner whose name can't be stored", func(t *testing.T) {
+		game := &GameSpy{}
+
+		stdout := &bytes.Buffer{}
+		in := userSends("3", "Cl/eo wins", "Cleo wins")
+
+		cli := NewCLI(in, stdout, game)
+		cli.PlayPoker()
+
+		assertMessagesSentToUser(t, stdout, PlayerPrompt, validatePlayerName("Cl/eo").Error()+"\n")
+		assertFinishCalledWith(t, game, "Cleo")
+	})
+
+	t.Run("it tells the user when the win can't be saved", func(t *testing.T) {
+		game := &GameSpy{FinishErr: errors.New("disk full")}
+		stdout := &bytes.Buffer{}
+
+		cli := NewCLI(userSends("3", "Chris wins"), stdout, game)
+		cli.PlayPoker()
+
+		if !strings.Contains(stdout.String(), WinNotSavedMsg) {
+			t.Errorf("got %q want it to say %q", stdout.String(), WinNotSavedMsg)
+		}
+	})
+
+	t.Run("it does not finish the game without a winner", func(t *testing.T) {
+		game := &GameSpy{}
+
+		in := userSends("3", " wins")
+
+		cli := NewCLI(in, io.Discard, game)
+		cli.PlayPoker()
+
+		assertGameNotFinished(t, game)
+	})
+}
+
+func TestCLISharesStoreWithServer(t *testing.T) {
+	database := createTempFile(t, "")
+	store, err := NewFileSystemPlayerStore(database)
+	assertNoError(t, err)
+
+	game := NewTexasHoldem(dummyBlindAlerter, store)
+	NewCLI(userSends("3", "Chris wins"), io.Discard, game).PlayPoker()
+	NewCLI(userSends("4", "Chris wins"), io.Discard, game).PlayPoker()
+
+	server := NewPlayerServer(store, dummyGame, dummyRatings)
+	response := newScoreResponse(server, "Chris")
+
+	assertResponseBody(t, response.Body.String(), "2")
+}
+
+func assertGameStartedWith(t testing.TB, game *GameSpy, numberOfPlayersWanted int) {
+	t.Helper()
+	var got int
+	passed := retryUntil(500*time.Millisecond, func() bool {
+		game.mu.Lock()
+		defer game.mu.Unlock()
+		got = game.StartedWith
+		return got == numberOfPlayersWanted
+	})
+	if !passed {
+		t.Errorf("wanted Start called with %d but got %d", numberOfPlayersWanted, got)
+	}
+}
+
+func assertGameNotStarted(t testing.TB, game *GameSpy) {
+	t.Helper()
+	game.mu.Lock()
+	defer game.mu.Unlock()
+	if game.StartCalled {
+		t.Errorf("game should not have started")
+	}
+}
+
+func assertGameStopped(t testing.TB, game *GameSpy) {
+	t.Helper()
+	passed := retryUntil(500*time.Millisecond, func() bool {
+		game.mu.Lock()
+		defer game.mu.Unlock()
+		return game.StopCalled
+	})
+	if !passed {
+		t.Errorf("game was never stopped")
+	}
+}
+
+func assertGameNotFinished(t testing.TB, game *GameSpy) {
+	t.Helper()
+	game.mu.Lock()
+	defer game.mu.Unlock()
+	if game.FinishCalled {
+		t.Errorf("game should not have finished")
+	}
+}
+
+func assertFinishCalledWith(t testing.TB, game *GameSpy, winner string) {
+	t.Helper()
+	var got string
+	passed := retryUntil(500*time.Millisecond, func() bool {
+		game.mu.Lock()
+		defer game.mu.Unlock()
+		got = game.FinishedWith
+		return got == winner
+	})
+	if !passed {
+		t.Errorf("expected finish called with %q but got %q", winner, got)
+	}
+}
+
+func assertMessagesSentToUser(t testing.TB, stdout *bytes.Buffer, messages ...string) {
+	t.Helper()
+	want := strings.Join(messages, "")
+	got := stdout.String()
+	if got != want {
+		t.Errorf("got %q sent to stdout but expected %+v", got, messages)
+	}
+}
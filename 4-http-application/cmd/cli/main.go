@@ -0,0 +1,27 @@
+// cmd/cli/main.go
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	poker "oop-lectures/4-http-application"
+)
+
+func main() {
+	// the same league file the webserver uses; the store locks it for writes
+	// and rereads it when the other side has changed it, so neither loses
+	// the other's wins
+	store, close, err := poker.FileSystemPlayerStoreFromFile(poker.DBFileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer close()
+
+	game := poker.NewTexasHoldem(poker.BlindAlerterFunc(poker.Alerter), store)
+
+	fmt.Println("Let's play poker")
+	fmt.Println("Type {Name} wins to record a win")
+	poker.NewCLI(os.Stdin, os.Stdout, game).PlayPoker()
+}
@@ -0,0 +1,31 @@
+// cmd/webserver/main.go
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	poker "oop-lectures/4-http-application"
+)
+
+func main() {
+	cfg, err := poker.ParseConfig(os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := poker.Run(ctx, cfg); err != nil {
+		log.Fatal(err)
+	}
+}
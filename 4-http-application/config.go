@@ -0,0 +1,164 @@
+// config.go
+package poker
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is everything Run needs to start the webserver.
+type Config struct {
+	Host string
+	Port int
+
+	// Store is "file", "sqlite" or "memory". DBPath is the league file for
+	// the first two, whose Elo ratings are kept alongside it.
+	Store  string
+	DBPath string
+
+	KFactor        float64
+	AuthConfigPath string
+
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Port:            5000,
+		Store:           "file",
+		KFactor:         DefaultKFactor,
+		ReadTimeout:     5 * time.Second,
+		WriteTimeout:    10 * time.Second,
+		IdleTimeout:     120 * time.Second,
+		ShutdownTimeout: 15 * time.Second,
+	}
+}
+
+// Addr is the address the server listens on.
+func (c Config) Addr() string {
+	return c.Host + ":" + strconv.Itoa(c.Port)
+}
+
+func (c Config) dbPath() string {
+	if c.DBPath != "" {
+		return c.DBPath
+	}
+	if c.Store == "sqlite" {
+		return "poker.db"
+	}
+	return DBFileName
+}
+
+// ratingsPath sits next to the league, game.db.json keeping its ratings in
+// game.db.ratings.json.
+func (c Config) ratingsPath() string {
+	path := c.dbPath()
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".ratings.json"
+}
+
+// DBFileName is the league file the webserver and CLI share by default.
+const DBFileName = "game.db.json"
+
+// ParseConfig builds a Config from command line flags, falling back to
+// environment variables and then to DefaultConfig. getenv is usually
+// os.Getenv. Usage and flag errors are written to output.
+func ParseConfig(args []string, getenv func(string) string, output io.Writer) (Config, error) {
+	cfg, err := configFromEnv(DefaultConfig(), getenv)
+	if err != nil {
+		return cfg, err
+	}
+
+	fs := flag.NewFlagSet("webserver", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&cfg.Host, "host", cfg.Host, "interface to listen on, all of them if empty (env HOST)")
+	fs.IntVar(&cfg.Port, "port", cfg.Port, "port to listen on (env PORT)")
+	fs.StringVar(&cfg.Store, "store", cfg.Store, "where to keep the league: file, sqlite or memory (env POKER_STORE)")
+	fs.StringVar(&cfg.DBPath, "db", cfg.DBPath, "league file for the file and sqlite stores, "+DBFileName+" or poker.db if empty (env POKER_DB)")
+	fs.Float64Var(&cfg.KFactor, "k-factor", cfg.KFactor, "how far a single match can move an Elo rating (env POKER_K_FACTOR)")
+	fs.StringVar(&cfg.AuthConfigPath, "auth-config", cfg.AuthConfigPath, "JSON file of API keys and HMAC secrets; when set, every write needs one of them (env POKER_AUTH_CONFIG)")
+	fs.DurationVar(&cfg.ReadTimeout, "read-timeout", cfg.ReadTimeout, "longest time to read a request (env POKER_READ_TIMEOUT)")
+	fs.DurationVar(&cfg.WriteTimeout, "write-timeout", cfg.WriteTimeout, "longest time to write a response (env POKER_WRITE_TIMEOUT)")
+	fs.DurationVar(&cfg.IdleTimeout, "idle-timeout", cfg.IdleTimeout, "how long to keep an idle connection open (env POKER_IDLE_TIMEOUT)")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "how long to let requests finish when stopping (env POKER_SHUTDOWN_TIMEOUT)")
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	if fs.NArg() > 0 {
+		return cfg, fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	return cfg, cfg.validate()
+}
+
+func configFromEnv(cfg Config, getenv func(string) string) (Config, error) {
+	texts := map[string]*string{
+		"HOST":              &cfg.Host,
+		"POKER_STORE":       &cfg.Store,
+		"POKER_DB":          &cfg.DBPath,
+		"POKER_AUTH_CONFIG": &cfg.AuthConfigPath,
+	}
+	for key, field := range texts {
+		if value := getenv(key); value != "" {
+			*field = value
+		}
+	}
+
+	if value := getenv("PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return cfg, fmt.Errorf("PORT must be a number, got %q", value)
+		}
+		cfg.Port = port
+	}
+
+	if value := getenv("POKER_K_FACTOR"); value != "" {
+		k, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("POKER_K_FACTOR must be a number, got %q", value)
+		}
+		cfg.KFactor = k
+	}
+
+	durations := map[string]*time.Duration{
+		"POKER_READ_TIMEOUT":     &cfg.ReadTimeout,
+		"POKER_WRITE_TIMEOUT":    &cfg.WriteTimeout,
+		"POKER_IDLE_TIMEOUT":     &cfg.IdleTimeout,
+		"POKER_SHUTDOWN_TIMEOUT": &cfg.ShutdownTimeout,
+	}
+	for key, field := range durations {
+		value := getenv(key)
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return cfg, fmt.Errorf("%s must be a duration like 10s, got %q", key, value)
+		}
+		*field = d
+	}
+
+	return cfg, nil
+}
+
+func (c Config) validate() error {
+	switch c.Store {
+	case "file", "sqlite", "memory":
+	default:
+		return fmt.Errorf("unknown store %q, use file, sqlite or memory", c.Store)
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("port %d is out of range", c.Port)
+	}
+	if c.KFactor <= 0 {
+		return fmt.Errorf("k-factor must be positive, got %v", c.KFactor)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// config_test.go
+package poker
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"testing"
+	"time"
+)
+
+func envOf(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func TestParseConfig(t *testing.T) {
+
+	t.Run("defaults", func(t *testing.T) {
+		got, err := ParseConfig(nil, envOf(nil), io.Discard)
+		assertNoError(t, err)
+
+		assertConfig(t, got, DefaultConfig())
+		if got.Addr() != ":5000" {
+			t.Errorf("got addr %q want %q", got.Addr(), ":5000")
+		}
+		if got.dbPath() != DBFileName {
+			t.Errorf("got db path %q want %q", got.dbPath(), DBFileName)
+		}
+	})
+
+	t.Run("environment overrides defaults", func(t *testing.T) {
+		got, err := ParseConfig(nil, envOf(map[string]string{
+			"PORT":                "8080",
+			"POKER_STORE":         "sqlite",
+			"POKER_K_FACTOR":      "16",
+			"POKER_READ_TIMEOUT":  "2s",
+			"POKER_IDLE_TIMEOUT":  "1m",
+			"POKER_AUTH_CONFIG":   "auth.json",
+			"POKER_WRITE_TIMEOUT": "3s",
+		}), io.Discard)
+		assertNoError(t, err)
+
+		want := DefaultConfig()
+		want.Port = 8080
+		want.Store = "sqlite"
+		want.KFactor = 16
+		want.ReadTimeout = 2 * time.Second
+		want.WriteTimeout = 3 * time.Second
+		want.IdleTimeout = time.Minute
+		want.AuthConfigPath = "auth.json"
+		assertConfig(t, got, want)
+
+		if got.dbPath() != "poker.db" {
+			t.Errorf("got db path %q want %q", got.dbPath(), "poker.db")
+		}
+	})
+
+	t.Run("flags override the environment", func(t *testing.T) {
+		got, err := ParseConfig(
+			[]string{"-port", "9000", "-store", "memory", "-host", "127.0.0.1", "-shutdown-timeout", "1s"},
+			envOf(map[string]string{"PORT": "8080", "POKER_STORE": "sqlite"}),
+			io.Discard,
+		)
+		assertNoError(t, err)
+
+		want := DefaultConfig()
+		want.Host = "127.0.0.1"
+		want.Port = 9000
+		want.Store = "memory"
+		want.ShutdownTimeout = time.Second
+		assertConfig(t, got, want)
+
+		if got.Addr() != "127.0.0.1:9000" {
+			t.Errorf("got addr %q want %q", got.Addr(), "127.0.0.1:9000")
+		}
+	})
+
+	t.Run("-h asks for help", func(t *testing.T) {
+		_, err := ParseConfig([]string{"-h"}, envOf(nil), io.Discard)
+
+		if !errors.Is(err, flag.ErrHelp) {
+			t.Errorf("got %v want %v", err, flag.ErrHelp)
+		}
+	})
+
+	bad := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{"unknown store", []string{"-store", "cloud"}, nil},
+		{"unknown flag", []string{"-colour", "red"}, nil},
+		{"stray argument", []string{"serve"}, nil},
+		{"port out of range", []string{"-port", "70000"}, nil},
+		{"zero k-factor", []string{"-k-factor", "0"}, nil},
+		{"PORT not a number", nil, map[string]string{"PORT": "http"}},
+		{"POKER_K_FACTOR not a number", nil, map[string]string{"POKER_K_FACTOR": "big"}},
+		{"timeout not a duration", nil, map[string]string{"POKER_READ_TIMEOUT": "5"}},
+	}
+	for _, c := range bad {
+		t.Run("rejects "+c.name, func(t *testing.T) {
+			_, err := ParseConfig(c.args, envOf(c.env), io.Discard)
+			if err == nil {
+				t.Error("expected an error but didn't get one")
+			}
+		})
+	}
+}
+
+func assertConfig(t testing.TB, got, want Config) {
+	t.Helper()
+	if got != want {
+		t.Errorf("got config %+v want %+v", got, want)
+	}
+}
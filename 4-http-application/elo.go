@@ -0,0 +1,186 @@
+// elo.go
+package poker
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"sort"
+	"sync"
+)
+
+const InitialRating = 1500.0
+const DefaultKFactor = 32.0
+
+// Elo works out rating changes after a match. K is the most a rating can move
+// against a single opponent.
+type Elo struct {
+	K float64
+}
+
+// expectedScore is the chance, between 0 and 1, that a player rated rating
+// beats one rated opponent.
+func expectedScore(rating, opponent float64) float64 {
+	return 1 / (1 + math.Pow(10, (opponent-rating)/400))
+}
+
+// Match treats a game as the winner beating every loser head to head. All
+// changes are worked out from the ratings before the match, so the order of
+// losers doesn't matter.
+func (e Elo) Match(winner float64, losers []float64) (newWinner float64, newLosers []float64) {
+	newWinner = winner
+	newLosers = make([]float64, len(losers))
+	for i, loser := range losers {
+		newWinner += e.K * (1 - expectedScore(winner, loser))
+		newLosers[i] = loser - e.K*expectedScore(loser, winner)
+	}
+	return newWinner, newLosers
+}
+
+// Ratings keeps a rating for every player who has played a match.
+type Ratings interface {
+	// RecordMatch moves the ratings of everyone in the match. If it returns
+	// an error the ratings are left as they were.
+	RecordMatch(winner string, losers []string) error
+	// GetRating returns InitialRating and false for players without a match.
+	GetRating(name string) (float64, bool)
+	// GetRatings returns every rated player's rating.
+	GetRatings() map[string]float64
+}
+
+// EloRatings is a Ratings that is safe for concurrent use. Ratings from
+// NewEloRatings only last as long as the process; EloRatingsFromFile keeps
+// them in a file.
+type EloRatings struct {
+	mu      sync.RWMutex
+	elo     Elo
+	ratings map[string]float64
+	path    string
+}
+
+func NewEloRatings(kFactor float64) *EloRatings {
+	return &EloRatings{
+		elo:     Elo{K: kFactor},
+		ratings: map[string]float64{},
+	}
+}
+
+// EloRatingsFromFile loads the ratings saved in the file at path, if there
+// is one, and saves them there after every match. Like the league file, the
+// ratings are replaced whole, so the file is always either the old ratings
+// or the new ones. Only one process should record matches into a file.
+func EloRatingsFromFile(path string, kFactor float64) (*EloRatings, error) {
+	r := NewEloRatings(kFactor)
+	r.path = path
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("problem reading ratings from %s, %v", path, err)
+	}
+	if len(data) == 0 {
+		return r, nil
+	}
+	if err := json.Unmarshal(data, &r.ratings); err != nil {
+		return nil, fmt.Errorf("problem parsing ratings from %s, %v", path, err)
+	}
+	if r.ratings == nil {
+		r.ratings = map[string]float64{}
+	}
+	return r, nil
+}
+
+func (r *EloRatings) RecordMatch(winner string, losers []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loserRatings := make([]float64, len(losers))
+	for i, loser := range losers {
+		loserRatings[i] = r.rating(loser)
+	}
+
+	newWinner, newLosers := r.elo.Match(r.rating(winner), loserRatings)
+
+	ratings := maps.Clone(r.ratings)
+	ratings[winner] = newWinner
+	for i, loser := range losers {
+		ratings[loser] = newLosers[i]
+	}
+
+	if r.path != "" {
+		if err := writeJSONFile(r.path, ratings); err != nil {
+			return fmt.Errorf("problem saving ratings to %s, %v", r.path, err)
+		}
+	}
+	r.ratings = ratings
+	return nil
+}
+
+func (r *EloRatings) GetRating(name string) (float64, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rating, ok := r.ratings[name]
+	if !ok {
+		return InitialRating, false
+	}
+	return rating, true
+}
+
+func (r *EloRatings) GetRatings() map[string]float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ratings := make(map[string]float64, len(r.ratings))
+	for name, rating := range r.ratings {
+		ratings[name] = rating
+	}
+	return ratings
+}
+
+func (r *EloRatings) rating(name string) float64 {
+	if rating, ok := r.ratings[name]; ok {
+		return rating
+	}
+	return InitialRating
+}
+
+// RatedPlayer is a league entry with the player's rating alongside the wins.
+type RatedPlayer struct {
+	Name   string
+	Wins   int
+	Rating float64
+}
+
+// rateLeague attaches ratings to the league and orders it by rating, highest
+// first, then by wins and name. Players who have only ever lost a match are
+// in the ratings but not the league, so they are added with no wins.
+func rateLeague(league League, ratings Ratings) []RatedPlayer {
+	known := ratings.GetRatings()
+
+	rated := make([]RatedPlayer, 0, len(league))
+	for _, p := range league {
+		rating, ok := known[p.Name]
+		if !ok {
+			rating = InitialRating
+		}
+		rated = append(rated, RatedPlayer{p.Name, p.Wins, rating})
+		delete(known, p.Name)
+	}
+	for name, rating := range known {
+		rated = append(rated, RatedPlayer{name, 0, rating})
+	}
+
+	sort.SliceStable(rated, func(i, j int) bool {
+		if rated[i].Rating != rated[j].Rating {
+			return rated[i].Rating > rated[j].Rating
+		}
+		if rated[i].Wins != rated[j].Wins {
+			return rated[i].Wins > rated[j].Wins
+		}
+		return rated[i].Name < rated[j].Name
+	})
+	return rated
+}
@@ -0,0 +1,188 @@
+// elo_test.go
+package poker
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpectedScore(t *testing.T) {
+	cases := []struct {
+		rating, opponent, want float64
+	}{
+		{1500, 1500, 0.5},
+		{1900, 1500, 1 / 1.1},
+		{1500, 1900, 1 - 1/1.1},
+		{2000, 1200, 1 / (1 + math.Pow(10, -2))},
+	}
+
+	for _, c := range cases {
+		got := expectedScore(c.rating, c.opponent)
+		assertRating(t, got, c.want)
+	}
+}
+
+func TestElo_Match(t *testing.T) {
+
+	t.Run("equal players swap half the K-factor", func(t *testing.T) {
+		winner, losers := Elo{K: 32}.Match(1500, []float64{1500})
+
+		assertRating(t, winner, 1516)
+		assertRating(t, losers[0], 1484)
+	})
+
+	t.Run("the K-factor scales the change", func(t *testing.T) {
+		winner, losers := Elo{K: 16}.Match(1500, []float64{1500})
+
+		assertRating(t, winner, 1508)
+		assertRating(t, losers[0], 1492)
+	})
+
+	t.Run("a favourite gains little for beating an outsider", func(t *testing.T) {
+		winner, losers := Elo{K: 32}.Match(1900, []float64{1500})
+
+		assertRating(t, winner, 1900+32*(1-1/1.1))
+		assertRating(t, losers[0], 1500-32*(1-1/1.1))
+	})
+
+	t.Run("an outsider gains a lot for beating a favourite", func(t *testing.T) {
+		winner, losers := Elo{K: 32}.Match(1500, []float64{1900})
+
+		assertRating(t, winner, 1500+32/1.1)
+		assertRating(t, losers[0], 1900-32/1.1)
+	})
+
+	t.Run("the winner beats every loser head to head", func(t *testing.T) {
+		winner, losers := Elo{K: 32}.Match(1500, []float64{1500, 1500, 1500})
+
+		assertRating(t, winner, 1548)
+		for _, loser := range losers {
+			assertRating(t, loser, 1484)
+		}
+	})
+
+	t.Run("ratings are conserved", func(t *testing.T) {
+		before := []float64{1700, 1320, 1555}
+		winner, losers := Elo{K: 24}.Match(1410, before)
+
+		total := winner
+		for _, loser := range losers {
+			total += loser
+		}
+		assertRating(t, total, 1410+1700+1320+1555)
+	})
+}
+
+func TestEloRatings(t *testing.T) {
+
+	t.Run("unrated players start at the initial rating", func(t *testing.T) {
+		ratings := NewEloRatings(DefaultKFactor)
+
+		rating, ok := ratings.GetRating("Pepper")
+
+		assertRating(t, rating, InitialRating)
+		if ok {
+			t.Error("Pepper should not be rated yet")
+		}
+	})
+
+	t.Run("matches build on earlier ratings", func(t *testing.T) {
+		ratings := NewEloRatings(32)
+
+		ratings.RecordMatch("Pepper", []string{"Cleo"})
+		ratings.RecordMatch("Cleo", []string{"Pepper"})
+
+		pepper, _ := ratings.GetRating("Pepper")
+		cleo, _ := ratings.GetRating("Cleo")
+
+		// Cleo was the outsider on 1484 against 1516 the second time
+		swing := 32 * (1 - expectedScore(1484, 1516))
+		assertRating(t, pepper, 1516-swing)
+		assertRating(t, cleo, 1484+swing)
+	})
+
+	t.Run("rated league includes players who only lost", func(t *testing.T) {
+		ratings := NewEloRatings(32)
+		ratings.RecordMatch("Pepper", []string{"Cleo"})
+
+		league := League{{"Pepper", 1}, {"Chris", 4}}
+
+		got := rateLeague(league, ratings)
+		want := []RatedPlayer{
+			{"Pepper", 1, 1516},
+			{"Chris", 4, InitialRating},
+			{"Cleo", 0, 1484},
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("got %v want %v", got, want)
+		}
+		for i := range want {
+			if got[i].Name != want[i].Name || got[i].Wins != want[i].Wins {
+				t.Errorf("got %v want %v", got[i], want[i])
+			}
+			assertRating(t, got[i].Rating, want[i].Rating)
+		}
+	})
+}
+
+func TestEloRatingsFromFile(t *testing.T) {
+
+	t.Run("keeps the ratings between opens", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ratings.json")
+
+		ratings, err := EloRatingsFromFile(path, 32)
+		assertNoError(t, err)
+		assertNoError(t, ratings.RecordMatch("Pepper", []string{"Cleo"}))
+
+		reopened, err := EloRatingsFromFile(path, 32)
+		assertNoError(t, err)
+
+		pepper, ok := reopened.GetRating("Pepper")
+		if !ok {
+			t.Fatal("Pepper's rating was lost")
+		}
+		assertRating(t, pepper, 1516)
+		cleo, _ := reopened.GetRating("Cleo")
+		assertRating(t, cleo, 1484)
+	})
+
+	t.Run("starts with no ratings when there is no file", func(t *testing.T) {
+		ratings, err := EloRatingsFromFile(filepath.Join(t.TempDir(), "ratings.json"), 32)
+		assertNoError(t, err)
+
+		if got := ratings.GetRatings(); len(got) != 0 {
+			t.Errorf("got ratings %v want none", got)
+		}
+	})
+
+	t.Run("leaves the ratings alone when they can't be saved", func(t *testing.T) {
+		ratings, err := EloRatingsFromFile(filepath.Join(t.TempDir(), "missing", "ratings.json"), 32)
+		assertNoError(t, err)
+
+		if err := ratings.RecordMatch("Pepper", []string{"Cleo"}); err == nil {
+			t.Fatal("expected an error but didn't get one")
+		}
+		if _, ok := ratings.GetRating("Pepper"); ok {
+			t.Error("Pepper was rated even though the match wasn't saved")
+		}
+	})
+
+	t.Run("rejects a broken file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ratings.json")
+		assertNoError(t, os.WriteFile(path, []byte("{"), 0644))
+
+		if _, err := EloRatingsFromFile(path, 32); err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+	})
+}
+
+func assertRating(t testing.TB, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("got rating %v want %v", got, want)
+	}
+}
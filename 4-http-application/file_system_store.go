@@ -0,0 +1,328 @@
+// file_system_store.go
+package poker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileSystemPlayerStore keeps the league as JSON in a file. Each player is
+// stored with their win count and the dated wins behind it, so files written
+// before history was kept still load: their wins just have no date.
+type FileSystemPlayerStore struct {
+	mu    sync.Mutex
+	file  leagueFile
+	wins  winLog
+	clock Clock
+}
+
+// leagueFile is where a FileSystemPlayerStore reads and writes the league.
+type leagueFile interface {
+	read() ([]playerRecord, error)
+	write(records []playerRecord) error
+	// lock keeps other processes from writing the league until unlock is
+	// called.
+	lock() (unlock func(), err error)
+	// changed says whether someone else has written the league since it was
+	// last read or written here.
+	changed() bool
+}
+
+type playerRecord struct {
+	Name    string
+	Wins    int
+	History []Win `json:",omitempty"`
+}
+
+// NewFileSystemPlayerStore keeps the league in database, rewriting it in
+// place on every write. That isn't atomic: a crash part way through a write
+// can leave a corrupt league. FileSystemPlayerStoreFromFile doesn't have
+// that problem, so use it for anything backed by a real file.
+func NewFileSystemPlayerStore(database io.ReadWriteSeeker, opts ...StoreOption) (*FileSystemPlayerStore, error) {
+	err := initialisePlayerDB(database)
+	if err != nil {
+		return nil, fmt.Errorf("problem initialising player db, %v", err)
+	}
+	return newFileSystemPlayerStore(&seekerFile{database}, opts)
+}
+
+func newFileSystemPlayerStore(file leagueFile, opts []StoreOption) (*FileSystemPlayerStore, error) {
+	records, err := file.read()
+	if err != nil {
+		return nil, fmt.Errorf("problem loading player store, %v", err)
+	}
+	return &FileSystemPlayerStore{
+		file:  file,
+		wins:  loadWins(records),
+		clock: newStoreOptions(opts).clock,
+	}, nil
+}
+
+func loadWins(records []playerRecord) winLog {
+	wins := winLog{}
+	for _, r := range records {
+		wins[r.Name] = playerWins{undated: max(r.Wins-len(r.History), 0), dated: r.History}
+	}
+	return wins
+}
+
+func (f *FileSystemPlayerStore) GetLeague() League {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reloadForRead()
+	return f.wins.league()
+}
+
+func (f *FileSystemPlayerStore) GetPlayerScore(name string) int {
+	score, _ := f.LookupPlayerScore(name)
+	return score
+}
+
+func (f *FileSystemPlayerStore) LookupPlayerScore(name string) (int, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reloadForRead()
+	return f.wins.score(name)
+}
+
+func (f *FileSystemPlayerStore) GetPlayerHistory(name string, from, to time.Time) History {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reloadForRead()
+	return f.wins.history(name, from, to)
+}
+
+func (f *FileSystemPlayerStore) RecordWin(name string) error {
+	return f.RecordGameWin(name, "")
+}
+
+// RecordGameWin adds the win and rewrites the league file. If the file can't
+// be written the win is dropped, so the store still matches the file.
+func (f *FileSystemPlayerStore) RecordGameWin(name, gameID string) error {
+	err := f.update(func(wins winLog) winLog {
+		wins.record(name, Win{At: f.clock.now(), GameID: gameID})
+		return wins
+	})
+	if err != nil {
+		return fmt.Errorf("problem recording win for %s, %v", name, err)
+	}
+	return nil
+}
+
+// ImportLeague applies the import and rewrites the league file. If the file
+// can't be written the store keeps the league it had.
+func (f *FileSystemPlayerStore) ImportLeague(league League, replace bool) error {
+	return f.update(func(wins winLog) winLog {
+		return wins.imported(league, replace)
+	})
+}
+
+// update changes the league with change and writes it out, holding the file
+// lock from reading the latest league to writing the new one so a change
+// made by another process in between isn't lost. change gets a copy, so the
+// store keeps the league it had if the write fails.
+func (f *FileSystemPlayerStore) update(change func(winLog) winLog) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	unlock, err := f.file.lock()
+	if err != nil {
+		return fmt.Errorf("problem locking league, %v", err)
+	}
+	defer unlock()
+
+	if err := f.reload(); err != nil {
+		return err
+	}
+	next := change(maps.Clone(f.wins))
+	if err := f.file.write(recordsOf(next)); err != nil {
+		return fmt.Errorf("problem writing league, %v", err)
+	}
+	f.wins = next
+	return nil
+}
+
+// reload reads the league again if someone else has written it.
+func (f *FileSystemPlayerStore) reload() error {
+	if !f.file.changed() {
+		return nil
+	}
+	records, err := f.file.read()
+	if err != nil {
+		return fmt.Errorf("problem reloading league, %v", err)
+	}
+	f.wins = loadWins(records)
+	return nil
+}
+
+// reloadForRead is reload for reads, which carry on with the league they
+// have if it can't be read.
+func (f *FileSystemPlayerStore) reloadForRead() {
+	if err := f.reload(); err != nil {
+		log.Print(err)
+	}
+}
+
+func recordsOf(wins winLog) []playerRecord {
+	league := wins.league()
+	records := make([]playerRecord, 0, len(league))
+	for _, p := range league {
+		records = append(records, playerRecord{p.Name, p.Wins, wins[p.Name].dated})
+	}
+	return records
+}
+
+// seekerFile rewrites the league in place through a tape.
+type seekerFile struct {
+	database io.ReadWriteSeeker
+}
+
+func (s *seekerFile) read() ([]playerRecord, error) {
+	if _, err := s.database.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var records []playerRecord
+	if err := json.NewDecoder(s.database).Decode(&records); err != nil {
+		return nil, fmt.Errorf("problem parsing league, %v", err)
+	}
+	return records, nil
+}
+
+func (s *seekerFile) write(records []playerRecord) error {
+	return json.NewEncoder(&tape{s.database}).Encode(records)
+}
+
+// lock does nothing; a bare database has no name other processes could
+// open it by.
+func (s *seekerFile) lock() (func(), error) {
+	return func() {}, nil
+}
+
+func (s *seekerFile) changed() bool {
+	return false
+}
+
+func initialisePlayerDB(database io.ReadWriteSeeker) error {
+	size, err := database.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("problem getting size of player db, %v", err)
+	}
+
+	if size == 0 {
+		if _, err := database.Write([]byte("[]")); err != nil {
+			return fmt.Errorf("problem writing empty league, %v", err)
+		}
+	}
+
+	_, err = database.Seek(0, io.SeekStart)
+	return err
+}
+
+// FileSystemPlayerStoreFromFile keeps the league in the file at path,
+// creating it if it doesn't exist. Every write goes to a temporary file in
+// the same directory which is then renamed over path, so the league on disk
+// is always either the old one or the new one.
+//
+// Several processes can share the file, like the webserver and the CLI do.
+// Writes take a lock on path+".lock" and reread the league first if someone
+// else has written it, and reads pick up their changes too. The returned
+// func is there for symmetry with SQLPlayerStoreFromFile; no file is held
+// open.
+func FileSystemPlayerStoreFromFile(path string, opts ...StoreOption) (*FileSystemPlayerStore, func(), error) {
+	file := &pathFile{path: path}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := file.write([]playerRecord{}); err != nil {
+			return nil, nil, fmt.Errorf("problem creating %s %v", path, err)
+		}
+	}
+
+	store, err := newFileSystemPlayerStore(file, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("problem creating file system player store, %v", err)
+	}
+	return store, func() {}, nil
+}
+
+// pathFile replaces the league file whole on every write. Each write makes
+// a new file, so a file other than the one last seen here means someone
+// else has written the league.
+type pathFile struct {
+	path string
+	seen os.FileInfo
+}
+
+func (p *pathFile) read() ([]playerRecord, error) {
+	seen, err := os.Stat(p.path)
+	if err != nil {
+		return nil, err
+	}
+	p.seen = seen
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+	var records []playerRecord
+	if len(data) == 0 {
+		return records, nil
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("problem parsing league, %v", err)
+	}
+	return records, nil
+}
+
+func (p *pathFile) write(records []playerRecord) error {
+	if err := writeJSONFile(p.path, records); err != nil {
+		return err
+	}
+	p.seen, _ = os.Stat(p.path)
+	return nil
+}
+
+// writeJSONFile encodes v to a temporary file next to path and renames it
+// over path, so readers see either the old contents or the new ones.
+func writeJSONFile(path string, v any) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// a no-op once the rename has happened
+	defer os.Remove(tmp.Name())
+
+	err = json.NewEncoder(tmp).Encode(v)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (p *pathFile) lock() (func(), error) {
+	return lockFile(p.path + ".lock")
+}
+
+func (p *pathFile) changed() bool {
+	now, err := os.Stat(p.path)
+	if err != nil {
+		// gone or unreadable; keep the league there is
+		return false
+	}
+	return p.seen == nil || !os.SameFile(p.seen, now) ||
+		!p.seen.ModTime().Equal(now.ModTime()) || p.seen.Size() != now.Size()
+}
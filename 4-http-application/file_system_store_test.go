@@ -0,0 +1,321 @@
+// file_system_store_test.go
+package poker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileSystemStore(t *testing.T) {
+
+	t.Run("league sorted by wins", func(t *testing.T) {
+		database := createTempFile(t, `[
+			{"Name": "Cleo", "Wins": 10},
+			{"Name": "Chris", "Wins": 33}]`)
+
+		store, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+
+		got := store.GetLeague()
+		want := League{
+			{"Chris", 33},
+			{"Cleo", 10},
+		}
+		assertLeague(t, got, want)
+
+		// read again
+		got = store.GetLeague()
+		assertLeague(t, got, want)
+	})
+
+	t.Run("get player score", func(t *testing.T) {
+		database := createTempFile(t, `[
+			{"Name": "Cleo", "Wins": 10},
+			{"Name": "Chris", "Wins": 33}]`)
+
+		store, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+
+		assertScoreEquals(t, store.GetPlayerScore("Chris"), 33)
+	})
+
+	t.Run("lookup tells unknown players apart", func(t *testing.T) {
+		database := createTempFile(t, `[
+			{"Name": "Cleo", "Wins": 0}]`)
+
+		store, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+
+		assertLookup(t, store, "Cleo", 0, true)
+		assertLookup(t, store, "Chris", 0, false)
+	})
+
+	t.Run("store wins for existing players", func(t *testing.T) {
+		database := createTempFile(t, `[
+			{"Name": "Cleo", "Wins": 10},
+			{"Name": "Chris", "Wins": 33}]`)
+
+		store, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+
+		store.RecordWin("Chris")
+
+		assertScoreEquals(t, store.GetPlayerScore("Chris"), 34)
+	})
+
+	t.Run("store wins for new players", func(t *testing.T) {
+		database := createTempFile(t, `[
+			{"Name": "Cleo", "Wins": 10},
+			{"Name": "Chris", "Wins": 33}]`)
+
+		store, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+
+		store.RecordWin("Pepper")
+
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 1)
+	})
+
+	t.Run("loads huge scores without a win for each", func(t *testing.T) {
+		database := createTempFile(t, `[
+			{"Name": "Cleo", "Wins": 2000000000}]`)
+
+		store, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+
+		assertScoreEquals(t, store.GetPlayerScore("Cleo"), 2000000000)
+	})
+
+	t.Run("works with an empty file", func(t *testing.T) {
+		database := createTempFile(t, "")
+
+		_, err := NewFileSystemPlayerStore(database)
+
+		assertNoError(t, err)
+	})
+
+	t.Run("wins survive reopening the file", func(t *testing.T) {
+		database := createTempFile(t, "")
+
+		store, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+		store.RecordWin("Pepper")
+		store.RecordWin("Pepper")
+
+		reopened, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+
+		assertScoreEquals(t, reopened.GetPlayerScore("Pepper"), 2)
+	})
+
+	t.Run("imports survive reopening the file", func(t *testing.T) {
+		database := createTempFile(t, `[
+			{"Name": "Cleo", "Wins": 10},
+			{"Name": "Chris", "Wins": 33}]`)
+
+		store, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+		store.RecordWin("Cleo")
+		assertNoError(t, store.ImportLeague(League{{"Cleo", 4}, {"Tiest", 2}}, true))
+
+		reopened, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+
+		assertLeague(t, reopened.GetLeague(), League{{"Cleo", 4}, {"Tiest", 2}})
+		if history := reopened.GetPlayerHistory("Cleo", time.Time{}, time.Time{}); history.Undated != 3 || len(history.Wins) != 1 {
+			t.Errorf("lost Cleo's dated win, %v", history)
+		}
+	})
+
+	t.Run("records concurrent wins", func(t *testing.T) {
+		database := createTempFile(t, "")
+
+		store, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+
+		wantedCount := 200
+		var wg sync.WaitGroup
+		wg.Add(wantedCount)
+		for i := 0; i < wantedCount; i++ {
+			go func() {
+				defer wg.Done()
+				store.RecordWin("Pepper")
+				store.GetLeague()
+			}()
+		}
+		wg.Wait()
+
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), wantedCount)
+	})
+
+	t.Run("keeps the league it had when the file can't be written", func(t *testing.T) {
+		database := &failingFile{createTempFile(t, `[{"Name": "Cleo", "Wins": 10}]`), false}
+
+		store, err := NewFileSystemPlayerStore(database)
+		assertNoError(t, err)
+		database.failWrites = true
+		if err := store.RecordWin("Cleo"); err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+
+		assertScoreEquals(t, store.GetPlayerScore("Cleo"), 10)
+		if err := store.ImportLeague(League{{"Cleo", 1}}, true); err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+		assertScoreEquals(t, store.GetPlayerScore("Cleo"), 10)
+	})
+
+	t.Run("rejects a corrupt file", func(t *testing.T) {
+		database := createTempFile(t, "not json")
+
+		_, err := NewFileSystemPlayerStore(database)
+
+		if err == nil {
+			t.Fatal("expected an error but didn't get one")
+		}
+	})
+}
+
+func TestFileSystemStoreFromFile(t *testing.T) {
+
+	t.Run("creates the file and keeps wins across reopening", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), DBFileName)
+
+		store, _, err := FileSystemPlayerStoreFromFile(path)
+		assertNoError(t, err)
+		store.RecordWin("Pepper")
+		store.RecordWin("Pepper")
+
+		reopened, _, err := FileSystemPlayerStoreFromFile(path)
+		assertNoError(t, err)
+		assertScoreEquals(t, reopened.GetPlayerScore("Pepper"), 2)
+	})
+
+	t.Run("replaces the file instead of writing over it", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, DBFileName)
+		store, _, err := FileSystemPlayerStoreFromFile(path)
+		assertNoError(t, err)
+
+		before, err := os.Stat(path)
+		assertNoError(t, err)
+		store.RecordWin("Pepper")
+		after, err := os.Stat(path)
+		assertNoError(t, err)
+
+		if os.SameFile(before, after) {
+			t.Error("the league file was written in place")
+		}
+		leftovers, _ := filepath.Glob(path + ".tmp-*")
+		if len(leftovers) != 0 {
+			t.Errorf("left temporary files behind, %v", leftovers)
+		}
+	})
+
+	t.Run("keeps the league it had when the file can't be written", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "gone")
+		assertNoError(t, os.Mkdir(dir, 0755))
+		store, _, err := FileSystemPlayerStoreFromFile(filepath.Join(dir, DBFileName))
+		assertNoError(t, err)
+		store.RecordWin("Pepper")
+
+		assertNoError(t, os.RemoveAll(dir))
+		store.RecordWin("Pepper")
+
+		assertScoreEquals(t, store.GetPlayerScore("Pepper"), 1)
+	})
+
+	t.Run("shares the file with another store", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), DBFileName)
+		server, _, err := FileSystemPlayerStoreFromFile(path)
+		assertNoError(t, err)
+		cli, _, err := FileSystemPlayerStoreFromFile(path)
+		assertNoError(t, err)
+
+		server.RecordWin("Pepper")
+		cli.RecordWin("Cleo")
+		server.RecordWin("Pepper")
+
+		want := League{{"Pepper", 2}, {"Cleo", 1}}
+		assertLeague(t, server.GetLeague(), want)
+		assertLeague(t, cli.GetLeague(), want)
+	})
+
+	t.Run("waits for another store's lock before writing", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), DBFileName)
+		first, _, err := FileSystemPlayerStoreFromFile(path)
+		assertNoError(t, err)
+		second, _, err := FileSystemPlayerStoreFromFile(path)
+		assertNoError(t, err)
+
+		unlock, err := first.file.lock()
+		assertNoError(t, err)
+		done := make(chan struct{})
+		go func() {
+			second.RecordWin("Cleo")
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("wrote the league while another store held the lock")
+		case <-time.After(50 * time.Millisecond):
+		}
+		unlock()
+		<-done
+		assertScoreEquals(t, first.GetPlayerScore("Cleo"), 1)
+	})
+
+	t.Run("rejects a corrupt file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), DBFileName)
+		assertNoError(t, os.WriteFile(path, []byte("not json"), 0644))
+
+		if _, _, err := FileSystemPlayerStoreFromFile(path); err == nil {
+			t.Fatal("expected an error but didn't get one")
+		}
+	})
+}
+
+// failingFile is a league file whose writes can be made to fail.
+type failingFile struct {
+	*os.File
+	failWrites bool
+}
+
+func (f *failingFile) Write(p []byte) (int, error) {
+	if f.failWrites {
+		return 0, errors.New("disk full")
+	}
+	return f.File.Write(p)
+}
+
+func createTempFile(t testing.TB, initialData string) *os.File {
+	t.Helper()
+
+	tmpfile, err := os.CreateTemp(t.TempDir(), "db")
+	if err != nil {
+		t.Fatalf("could not create temp file %v", err)
+	}
+	t.Cleanup(func() { tmpfile.Close() })
+
+	tmpfile.Write([]byte(initialData))
+	return tmpfile
+}
+
+func assertScoreEquals(t testing.TB, got, want int) {
+	t.Helper()
+	if got != want {
+		t.Errorf("got %d want %d", got, want)
+	}
+}
+
+func assertNoError(t testing.TB, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("didn't expect an error but got one, %v", err)
+	}
+}
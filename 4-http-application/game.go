@@ -0,0 +1,21 @@
+// game.go
+package poker
+
+import "io"
+
+// Game manages the state of a game of poker.
+type Game interface {
+	// Start begins a game. The returned func stops anything the game still
+	// has scheduled, and must be called once the game is over or abandoned.
+	Start(numberOfPlayers int, alertsDestination io.Writer) (stop func())
+	// Finish records the winner. If it returns an error the win was not
+	// saved.
+	Finish(winner string) error
+}
+
+// MaxPlayers is the most players a game can have.
+const MaxPlayers = 10
+
+func validNumberOfPlayers(n int) bool {
+	return n >= 1 && n <= MaxPlayers
+}
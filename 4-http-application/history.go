@@ -0,0 +1,151 @@
+// history.go
+package poker
+
+import "time"
+
+// Win is a single recorded win.
+type Win struct {
+	At     time.Time
+	GameID string `json:",omitempty"`
+}
+
+// History is a player's wins in a time range, oldest first. Wins carried
+// over from before history was kept can't be dated, so they are only
+// counted, in Undated. They come before every dated win, so they are only
+// in a range that is open at the start.
+type History struct {
+	Undated int
+	Wins    []Win
+}
+
+// Len is how many wins the history covers.
+func (h History) Len() int {
+	return h.Undated + len(h.Wins)
+}
+
+// Clock tells a store what time it is when a win is recorded.
+type Clock func() time.Time
+
+func (c Clock) now() time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c()
+}
+
+// StoreOption changes how a player store is set up.
+type StoreOption func(*storeOptions)
+
+type storeOptions struct {
+	clock Clock
+}
+
+func newStoreOptions(opts []StoreOption) storeOptions {
+	var o storeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithClock stamps wins with the time clock gives instead of the real time.
+func WithClock(clock Clock) StoreOption {
+	return func(o *storeOptions) {
+		o.clock = clock
+	}
+}
+
+// winsBetween returns the wins at or after from and before to. A zero from
+// or to leaves that end of the range open.
+func winsBetween(wins []Win, from, to time.Time) []Win {
+	matched := []Win{}
+	for _, w := range wins {
+		if !from.IsZero() && w.At.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !w.At.Before(to) {
+			continue
+		}
+		matched = append(matched, w)
+	}
+	return matched
+}
+
+// playerWins is one player's wins: how many undated ones there are, which
+// all came first, then the dated ones in the order they happened. Undated
+// wins are only counted, so a score in the billions costs nothing to hold.
+type playerWins struct {
+	undated int
+	dated   []Win
+}
+
+func (p playerWins) score() int {
+	return p.undated + len(p.dated)
+}
+
+// winLog holds every player's wins. The score is simply how many wins a
+// player has; a player can be known with none. It does no locking of its
+// own.
+type winLog map[string]playerWins
+
+func (l winLog) record(name string, w Win) {
+	p := l[name]
+	p.dated = append(p.dated, w)
+	l[name] = p
+}
+
+func (l winLog) score(name string) (int, bool) {
+	p, ok := l[name]
+	return p.score(), ok
+}
+
+func (l winLog) history(name string, from, to time.Time) History {
+	p := l[name]
+	h := History{Wins: winsBetween(p.dated, from, to)}
+	if from.IsZero() {
+		h.Undated = p.undated
+	}
+	return h
+}
+
+func (l winLog) league() League {
+	league := League{}
+	for name, p := range l {
+		league = append(league, Player{name, p.score()})
+	}
+	league.sortByWins()
+	return league
+}
+
+// setScore gives the player exactly score wins. Wins are dropped oldest first
+// and any that have to be added are undated, so they sit before the rest.
+func (l winLog) setScore(name string, score int) {
+	p := l[name]
+	if score >= p.score() {
+		p.undated += score - p.score()
+		l[name] = p
+		return
+	}
+
+	drop := p.score() - score
+	fromUndated := min(drop, p.undated)
+	p.undated -= fromUndated
+	p.dated = append([]Win{}, p.dated[drop-fromUndated:]...)
+	l[name] = p
+}
+
+// imported returns a copy of the log with the scores in league applied. With
+// replace, players missing from league are left out of the copy.
+func (l winLog) imported(league League, replace bool) winLog {
+	next := winLog{}
+	if !replace {
+		for name, wins := range l {
+			next[name] = wins
+		}
+	}
+	for _, p := range league {
+		next[p.Name] = l[p.Name]
+		next.setScore(p.Name, p.Wins)
+	}
+	return next
+}
@@ -0,0 +1,79 @@
+// history_test.go
+package poker_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	poker "oop-lectures/4-http-application"
+	"oop-lectures/4-http-application/playerstoretest"
+)
+
+var gameNight = playerstoretest.GameNight
+
+func TestHistoryPersistence(t *testing.T) {
+
+	t.Run("file store keeps dated wins across reopening", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "league")
+		store, _, err := poker.FileSystemPlayerStoreFromFile(path, poker.WithClock(playerstoretest.StubClock(gameNight, time.Minute)))
+		assertNoError(t, err)
+		assertNoError(t, store.RecordGameWin("Pepper", "g1"))
+
+		reopened, _, err := poker.FileSystemPlayerStoreFromFile(path)
+		assertNoError(t, err)
+
+		playerstoretest.AssertHistory(t, reopened.GetPlayerHistory("Pepper", time.Time{}, time.Time{}), 0, []poker.Win{{At: gameNight, GameID: "g1"}})
+	})
+
+	t.Run("file store reads scores written before history as undated wins", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "league")
+		assertNoError(t, os.WriteFile(path, []byte(`[
+			{"Name": "Cleo", "Wins": 2}]`), 0o644))
+		store, _, err := poker.FileSystemPlayerStoreFromFile(path, poker.WithClock(playerstoretest.StubClock(gameNight, time.Minute)))
+		assertNoError(t, err)
+
+		assertNoError(t, store.RecordWin("Cleo"))
+
+		assertUndatedWinsKept(t, store)
+	})
+
+	t.Run("sql store reads scores written before history as undated wins", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "league.db")
+
+		db, err := sql.Open("sqlite", path)
+		assertNoError(t, err)
+		_, err = db.Exec(`CREATE TABLE players (name TEXT PRIMARY KEY, wins INTEGER NOT NULL DEFAULT 0);
+			INSERT INTO players (name, wins) VALUES ('Cleo', 2)`)
+		assertNoError(t, err)
+		db.Close()
+
+		store, closeDB, err := poker.SQLPlayerStoreFromFile(path, poker.WithClock(playerstoretest.StubClock(gameNight, time.Minute)))
+		assertNoError(t, err)
+		defer closeDB()
+
+		assertNoError(t, store.RecordWin("Cleo"))
+
+		assertUndatedWinsKept(t, store)
+	})
+}
+
+// assertUndatedWinsKept checks Cleo's two wins from before history are
+// still counted, undated, next to the one won on game night.
+func assertUndatedWinsKept(t testing.TB, store poker.PlayerStore) {
+	t.Helper()
+	if got := store.GetPlayerScore("Cleo"); got != 3 {
+		t.Errorf("got score %d want 3", got)
+	}
+	playerstoretest.AssertHistory(t, store.GetPlayerHistory("Cleo", time.Time{}, time.Time{}), 2, []poker.Win{{At: gameNight}})
+	playerstoretest.AssertHistory(t, store.GetPlayerHistory("Cleo", gameNight, time.Time{}), 0, []poker.Win{{At: gameNight}})
+}
+
+func assertNoError(t testing.TB, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("didn't expect an error but got one, %v", err)
+	}
+}
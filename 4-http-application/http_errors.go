@@ -0,0 +1,36 @@
+// http_errors.go
+package poker
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ErrorResponse is the JSON body PlayerServer sends with every error status.
+type ErrorResponse struct {
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("content-type", jsonContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Status: status, Error: message})
+}
+
+// allowMethods rejects any request whose method isn't listed with a 405 and
+// an Allow header naming the methods that would have worked.
+func allowMethods(next http.HandlerFunc, methods ...string) http.Handler {
+	allowed := strings.Join(methods, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, method := range methods {
+			if r.Method == method {
+				next(w, r)
+				return
+			}
+		}
+		w.Header().Set("Allow", allowed)
+		writeError(w, http.StatusMethodNotAllowed, "method "+r.Method+" not allowed, use "+allowed)
+	})
+}
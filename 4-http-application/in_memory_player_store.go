@@ -1,18 +1,61 @@
 // in_memory_player_store.go
-package main
+package poker
 
+import (
+	"sync"
+	"time"
+)
+
+// InMemoryPlayerStore keeps every win in memory behind a lock, so it is safe
+// for the concurrent requests PlayerServer receives.
 type InMemoryPlayerStore struct {
-	store map[string]int
+	mu    sync.RWMutex
+	wins  winLog
+	clock Clock
 }
 
-func NewInMemoryPlayerStore() *InMemoryPlayerStore {
-	return &InMemoryPlayerStore{map[string]int{}}
+func NewInMemoryPlayerStore(opts ...StoreOption) *InMemoryPlayerStore {
+	return &InMemoryPlayerStore{wins: winLog{}, clock: newStoreOptions(opts).clock}
 }
 
 func (i *InMemoryPlayerStore) GetPlayerScore(name string) int {
-	return i.store[name]
+	score, _ := i.LookupPlayerScore(name)
+	return score
+}
+
+func (i *InMemoryPlayerStore) LookupPlayerScore(name string) (int, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.wins.score(name)
+}
+
+func (i *InMemoryPlayerStore) RecordWin(name string) error {
+	return i.RecordGameWin(name, "")
+}
+
+// RecordGameWin never fails; there is nothing to write.
+func (i *InMemoryPlayerStore) RecordGameWin(name, gameID string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.wins.record(name, Win{At: i.clock.now(), GameID: gameID})
+	return nil
+}
+
+func (i *InMemoryPlayerStore) GetPlayerHistory(name string, from, to time.Time) History {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.wins.history(name, from, to)
+}
+
+func (i *InMemoryPlayerStore) GetLeague() League {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.wins.league()
 }
 
-func (i *InMemoryPlayerStore) RecordWin(name string) {
-	i.store[name]++
+func (i *InMemoryPlayerStore) ImportLeague(league League, replace bool) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.wins = i.wins.imported(league, replace)
+	return nil
 }
@@ -0,0 +1,23 @@
+// in_memory_player_store_test.go
+package poker
+
+import "testing"
+
+func TestInMemoryPlayerStore(t *testing.T) {
+
+	t.Run("lookup tells unknown players apart", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		store.RecordWin("Cleo")
+
+		assertLookup(t, store, "Cleo", 1, true)
+		assertLookup(t, store, "Chris", 0, false)
+	})
+}
+
+func assertLookup(t testing.TB, store PlayerStore, name string, wantScore int, wantFound bool) {
+	t.Helper()
+	score, found := store.LookupPlayerScore(name)
+	if score != wantScore || found != wantFound {
+		t.Errorf("LookupPlayerScore(%q) = %d, %v want %d, %v", name, score, found, wantScore, wantFound)
+	}
+}
@@ -0,0 +1,116 @@
+// instrument.go
+package poker
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"oop-lectures/4-http-application/internal/metrics"
+)
+
+// Instrument counts and times every request next serves and answers GET
+// /metrics itself with those numbers and the size of the league, in the
+// Prometheus text format.
+func Instrument(next http.Handler, store PlayerStore) http.Handler {
+	registry := metrics.NewRegistry()
+
+	requests := registry.NewCounterVec("poker_http_requests_total",
+		"HTTP requests served, by route, method and status code.",
+		"route", "method", "status")
+	latency := registry.NewHistogramVec("poker_http_request_duration_seconds",
+		"Time taken to serve HTTP requests, by route and method.",
+		metrics.DefaultBuckets, "route", "method")
+	registry.NewGaugeFunc("poker_players",
+		"Players in the league.",
+		func() float64 { return float64(len(store.GetLeague())) })
+
+	scrape := allowMethods(registry.Handler().ServeHTTP, http.MethodGet)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		if r.URL.Path == "/metrics" {
+			scrape.ServeHTTP(recorder, r)
+		} else {
+			next.ServeHTTP(recorder, r)
+		}
+
+		route, method := routeOf(r.URL.Path), methodOf(r.Method)
+		requests.Inc(route, method, strconv.Itoa(recorder.status))
+		latency.Observe(time.Since(start).Seconds(), route, method)
+	})
+}
+
+// routeOf maps a path to the route that served it, so every player doesn't
+// get their own set of metrics.
+func routeOf(path string) string {
+	switch path {
+	case "/league", "/league/export", "/league/import", "/matches", "/game", "/ws", "/metrics":
+		return path
+	}
+
+	rest, ok := strings.CutPrefix(path, "/players/")
+	switch {
+	case !ok:
+		return "other"
+	case strings.HasSuffix(rest, historySuffix):
+		return "/players/{name}" + historySuffix
+	case strings.HasSuffix(rest, ratingSuffix):
+		return "/players/{name}" + ratingSuffix
+	default:
+		return "/players/{name}"
+	}
+}
+
+// methodOf keeps the standard methods and lumps anything else a client
+// makes up into "other", for the same reason as routeOf.
+func methodOf(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "other"
+}
+
+// statusRecorder remembers the status code written through it. It passes
+// Hijack on so the websocket handshake still works behind it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.status = status
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(p)
+}
+
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	// a hijacked connection switches protocols
+	s.status = http.StatusSwitchingProtocols
+	s.wroteHeader = true
+	return hijacker.Hijack()
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,154 @@
+// instrument_test.go
+package poker
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInstrument(t *testing.T) {
+
+	t.Run("counts requests by route, method and status", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		server := Instrument(NewPlayerServer(store, dummyGame, dummyRatings), store)
+
+		server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest("Pepper"))
+		server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest("Cleo"))
+		server.ServeHTTP(httptest.NewRecorder(), newGetScoreRequest("Pepper"))
+		server.ServeHTTP(httptest.NewRecorder(), newGetScoreRequest("Lloyd"))
+		server.ServeHTTP(httptest.NewRecorder(), newHistoryRequest("Pepper"))
+		server.ServeHTTP(httptest.NewRecorder(), newLeagueRequest())
+
+		body := scrapeMetrics(t, server)
+
+		assertMetric(t, body, `poker_http_requests_total{route="/players/{name}",method="POST",status="202"} 2`)
+		assertMetric(t, body, `poker_http_requests_total{route="/players/{name}",method="GET",status="200"} 1`)
+		assertMetric(t, body, `poker_http_requests_total{route="/players/{name}",method="GET",status="404"} 1`)
+		assertMetric(t, body, `poker_http_requests_total{route="/players/{name}/history",method="GET",status="200"} 1`)
+		assertMetric(t, body, `poker_http_requests_total{route="/league",method="GET",status="200"} 1`)
+	})
+
+	t.Run("counts made up methods as other", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		server := Instrument(NewPlayerServer(store, dummyGame, dummyRatings), store)
+
+		for _, method := range []string{"FOO", "BAR", "get"} {
+			request, _ := http.NewRequest(method, "/league", nil)
+			server.ServeHTTP(httptest.NewRecorder(), request)
+		}
+
+		body := scrapeMetrics(t, server)
+
+		assertMetric(t, body, `poker_http_requests_total{route="/league",method="other",status="405"} 3`)
+		if strings.Contains(body, `method="FOO"`) || strings.Contains(body, `method="get"`) {
+			t.Errorf("got a made up method in the metrics %q", body)
+		}
+	})
+
+	t.Run("times requests", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		server := Instrument(NewPlayerServer(store, dummyGame, dummyRatings), store)
+
+		server.ServeHTTP(httptest.NewRecorder(), newLeagueRequest())
+		server.ServeHTTP(httptest.NewRecorder(), newLeagueRequest())
+
+		body := scrapeMetrics(t, server)
+
+		assertMetric(t, body, `# TYPE poker_http_request_duration_seconds histogram`)
+		assertMetric(t, body, `poker_http_request_duration_seconds_bucket{route="/league",method="GET",le="+Inf"} 2`)
+		assertMetric(t, body, `poker_http_request_duration_seconds_count{route="/league",method="GET"} 2`)
+	})
+
+	t.Run("reports the number of players", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		server := Instrument(NewPlayerServer(store, dummyGame, dummyRatings), store)
+
+		store.RecordWin("Pepper")
+		store.RecordWin("Cleo")
+		store.RecordWin("Cleo")
+
+		assertMetric(t, scrapeMetrics(t, server), "poker_players 2")
+	})
+
+	t.Run("counts 401s from the auth in front of the server", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		server := Instrument(newAuthedServer(store), store)
+
+		server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest("Pepper"))
+
+		assertMetric(t, scrapeMetrics(t, server), `poker_http_requests_total{route="/players/{name}",method="POST",status="401"} 1`)
+	})
+
+	t.Run("only GET on /metrics", func(t *testing.T) {
+		store := NewInMemoryPlayerStore()
+		server := Instrument(NewPlayerServer(store, dummyGame, dummyRatings), store)
+
+		request, _ := http.NewRequest(http.MethodPost, "/metrics", nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusMethodNotAllowed)
+	})
+
+	t.Run("websockets still work behind it", func(t *testing.T) {
+		game := &GameSpy{BlindAlert: []byte("Blind is 100")}
+		store := NewInMemoryPlayerStore()
+		server := httptest.NewServer(Instrument(NewPlayerServer(store, game, dummyRatings), store))
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+
+		writeWSMessage(t, ws, "3")
+		assertWebsocketGotMsg(t, ws, "Blind is 100")
+	})
+}
+
+func TestRouteOf(t *testing.T) {
+	cases := map[string]string{
+		"/league":                 "/league",
+		"/league/export":          "/league/export",
+		"/league/import":          "/league/import",
+		"/metrics":                "/metrics",
+		"/players/Pepper":         "/players/{name}",
+		"/players/Pepper/history": "/players/{name}/history",
+		"/players/Pepper/rating":  "/players/{name}/rating",
+		"/favicon.ico":            "other",
+	}
+
+	for path, want := range cases {
+		if got := routeOf(path); got != want {
+			t.Errorf("routeOf(%q) = %q want %q", path, got, want)
+		}
+	}
+}
+
+func newHistoryRequest(name string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "/players/"+name+"/history", nil)
+	return req
+}
+
+func scrapeMetrics(t *testing.T, server http.Handler) string {
+	t.Helper()
+
+	request, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	assertStatus(t, response.Code, http.StatusOK)
+	body, _ := io.ReadAll(response.Body)
+	return string(body)
+}
+
+func assertMetric(t testing.TB, body, line string) {
+	t.Helper()
+	for _, got := range strings.Split(body, "\n") {
+		if got == line {
+			return
+		}
+	}
+	t.Errorf("metrics are missing %q, got\n%s", line, body)
+}
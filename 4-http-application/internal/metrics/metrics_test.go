@@ -0,0 +1,121 @@
+// metrics_test.go
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+
+	t.Run("counters", func(t *testing.T) {
+		registry := NewRegistry()
+		requests := registry.NewCounterVec("requests_total", "Requests served.", "method", "status")
+
+		requests.Inc("POST", "202")
+		requests.Inc("GET", "200")
+		requests.Inc("GET", "200")
+
+		assertExposition(t, registry, `# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{method="GET",status="200"} 2
+requests_total{method="POST",status="202"} 1
+`)
+	})
+
+	t.Run("histograms", func(t *testing.T) {
+		registry := NewRegistry()
+		latency := registry.NewHistogramVec("latency_seconds", "Time taken.", []float64{0.5, 0.1, 1}, "route")
+
+		latency.Observe(0.05, "/league")
+		latency.Observe(0.3, "/league")
+		latency.Observe(2, "/league")
+
+		assertExposition(t, registry, `# HELP latency_seconds Time taken.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/league",le="0.1"} 1
+latency_seconds_bucket{route="/league",le="0.5"} 2
+latency_seconds_bucket{route="/league",le="1"} 2
+latency_seconds_bucket{route="/league",le="+Inf"} 3
+latency_seconds_sum{route="/league"} 2.35
+latency_seconds_count{route="/league"} 3
+`)
+	})
+
+	t.Run("gauges are read when written", func(t *testing.T) {
+		registry := NewRegistry()
+		players := 3.0
+		registry.NewGaugeFunc("players", "Players in the league.", func() float64 { return players })
+
+		players = 5
+
+		assertExposition(t, registry, `# HELP players Players in the league.
+# TYPE players gauge
+players 5
+`)
+	})
+
+	t.Run("label values and help are escaped", func(t *testing.T) {
+		registry := NewRegistry()
+		c := registry.NewCounterVec("odd_total", "A \\ and a\nnewline.", "name")
+
+		c.Inc("say \"hi\"\n")
+
+		assertExposition(t, registry, `# HELP odd_total A \\ and a\nnewline.
+# TYPE odd_total counter
+odd_total{name="say \"hi\"\n"} 1
+`)
+	})
+
+	t.Run("metrics come out in the order they were registered", func(t *testing.T) {
+		registry := NewRegistry()
+		registry.NewGaugeFunc("b", "B.", func() float64 { return 1 })
+		registry.NewGaugeFunc("a", "A.", func() float64 { return 2 })
+
+		assertExposition(t, registry, `# HELP b B.
+# TYPE b gauge
+b 1
+# HELP a A.
+# TYPE a gauge
+a 2
+`)
+	})
+}
+
+func TestCounterVec_PanicsOnWrongLabelCount(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+
+	NewRegistry().NewCounterVec("c_total", "C.", "method").Inc("GET", "200")
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounterVec("c_total", "C.").Inc()
+
+	response := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got := response.Header().Get("content-type"); got != ContentType {
+		t.Errorf("got content-type %q want %q", got, ContentType)
+	}
+	if !strings.Contains(response.Body.String(), "c_total 1\n") {
+		t.Errorf("got body %q", response.Body.String())
+	}
+}
+
+func assertExposition(t testing.TB, registry *Registry, want string) {
+	t.Helper()
+	var got strings.Builder
+	if _, err := registry.WriteTo(&got); err != nil {
+		t.Fatalf("could not write metrics %v", err)
+	}
+	if got.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", got.String(), want)
+	}
+}
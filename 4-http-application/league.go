@@ -0,0 +1,22 @@
+// league.go
+package poker
+
+import "sort"
+
+type Player struct {
+	Name string
+	Wins int
+}
+
+type League []Player
+
+// sortByWins orders the league with the most wins first. Players on the same
+// number of wins are ordered by name so the output is stable.
+func (l League) sortByWins() {
+	sort.Slice(l, func(i, j int) bool {
+		if l[i].Wins != l[j].Wins {
+			return l[i].Wins > l[j].Wins
+		}
+		return l[i].Name < l[j].Name
+	})
+}
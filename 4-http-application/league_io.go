@@ -0,0 +1,317 @@
+// league_io.go
+package poker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+const csvContentType = "text/csv; charset=utf-8"
+const ndjsonContentType = "application/x-ndjson"
+
+// maxImportErrors caps how many problems an import reports, so a file in the
+// wrong format doesn't come back as one error per line.
+const maxImportErrors = 100
+
+// maxImportWins is the highest score an import will accept for a player.
+const maxImportWins = 1_000_000
+
+// maxNDJSONLine is the longest NDJSON line an import will read.
+const maxNDJSONLine = 64 * 1024
+
+var csvHeader = []string{"name", "wins"}
+
+// LineError is one problem with an imported league and the line it is on.
+type LineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// leagueFormat writes and reads the league one player per line.
+type leagueFormat struct {
+	name        string
+	contentType string
+	mediaTypes  []string
+	write       func(w io.Writer, league League) error
+	// read returns the problems it found with the input as LineErrors; the
+	// error is only for failing to read it at all.
+	read func(r io.Reader) (League, []LineError, error)
+}
+
+var ndjsonFormat = leagueFormat{
+	name:        "ndjson",
+	contentType: ndjsonContentType,
+	mediaTypes:  []string{"application/x-ndjson", "application/ndjson"},
+	write:       writeLeagueNDJSON,
+	read:        readLeagueNDJSON,
+}
+
+var csvFormat = leagueFormat{
+	name:        "csv",
+	contentType: csvContentType,
+	mediaTypes:  []string{"text/csv"},
+	write:       writeLeagueCSV,
+	read:        readLeagueCSV,
+}
+
+// leagueFormats is in order of preference, so a wildcard Accept gets NDJSON.
+var leagueFormats = []leagueFormat{ndjsonFormat, csvFormat}
+
+func (f leagueFormat) matches(mediaType string) bool {
+	if mediaType == "*/*" {
+		return true
+	}
+	for _, t := range f.mediaTypes {
+		kind, _, _ := strings.Cut(t, "/")
+		if mediaType == t || mediaType == kind+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateLeagueFormat picks the format the Accept header rates highest. An
+// empty header accepts anything.
+func negotiateLeagueFormat(accept string) (leagueFormat, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return leagueFormats[0], true
+	}
+
+	var best leagueFormat
+	bestQ, found := 0.0, false
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+		for _, format := range leagueFormats {
+			if format.matches(mediaType) {
+				best, bestQ, found = format, q, true
+				break
+			}
+		}
+	}
+	return best, found
+}
+
+// leagueFormatOf finds the format for the Content-Type of an upload.
+// Wildcards aren't allowed here; the sender knows what it is sending.
+func leagueFormatOf(contentType string) (leagueFormat, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return leagueFormat{}, false
+	}
+	for _, format := range leagueFormats {
+		for _, t := range format.mediaTypes {
+			if mediaType == t {
+				return format, true
+			}
+		}
+	}
+	return leagueFormat{}, false
+}
+
+func writeLeagueCSV(w io.Writer, league League) error {
+	out := csv.NewWriter(w)
+	out.Write(csvHeader)
+	for _, p := range league {
+		out.Write([]string{p.Name, strconv.Itoa(p.Wins)})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func writeLeagueNDJSON(w io.Writer, league League) error {
+	encoder := json.NewEncoder(w)
+	for _, p := range league {
+		if err := encoder.Encode(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLeagueCSV expects a name,wins header followed by one player per row.
+func readLeagueCSV(r io.Reader) (League, []LineError, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+	in.TrimLeadingSpace = true
+
+	var check leagueChecker
+	header := false
+	for !check.full() {
+		record, err := in.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			check.fail(parseErr.Line, parseErr.Err.Error())
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		line, _ := in.FieldPos(0)
+		if !header {
+			header = true
+			if !isCSVHeader(record) {
+				check.fail(line, fmt.Sprintf("header must be %q, got %q", strings.Join(csvHeader, ","), strings.Join(record, ",")))
+			}
+			continue
+		}
+		if len(record) != len(csvHeader) {
+			check.fail(line, fmt.Sprintf("want %d fields, got %d", len(csvHeader), len(record)))
+			continue
+		}
+
+		wins, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil {
+			check.fail(line, fmt.Sprintf("wins must be a whole number, got %q", record[1]))
+			continue
+		}
+		check.add(line, Player{record[0], wins})
+	}
+
+	if !header && !check.full() {
+		check.fail(1, fmt.Sprintf("missing header %q", strings.Join(csvHeader, ",")))
+	}
+	return check.result()
+}
+
+func isCSVHeader(record []string) bool {
+	if len(record) != len(csvHeader) {
+		return false
+	}
+	for i, field := range record {
+		if !strings.EqualFold(strings.TrimSpace(field), csvHeader[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ndjsonPlayer has pointer fields so a missing field can be told apart from
+// a zero one.
+type ndjsonPlayer struct {
+	Name *string
+	Wins *int
+}
+
+// readLeagueNDJSON expects one JSON player per line. Blank lines are
+// skipped.
+func readLeagueNDJSON(r io.Reader) (League, []LineError, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxNDJSONLine)
+
+	var check leagueChecker
+	line := 0
+	for !check.full() && scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+		var p ndjsonPlayer
+		if err := decoder.Decode(&p); err != nil {
+			check.fail(line, fmt.Sprintf("could not parse player, %v", err))
+			continue
+		}
+		if decoder.More() {
+			check.fail(line, "want one player per line")
+			continue
+		}
+		if p.Name == nil || p.Wins == nil {
+			check.fail(line, "player needs both Name and Wins")
+			continue
+		}
+		check.add(line, Player{*p.Name, *p.Wins})
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			check.fail(line+1, fmt.Sprintf("line is longer than %d bytes", maxNDJSONLine))
+			return check.result()
+		}
+		return nil, nil, err
+	}
+	return check.result()
+}
+
+// leagueChecker collects the players of an import and everything wrong with
+// them, so the whole file can be reported on before anything is changed.
+type leagueChecker struct {
+	league   League
+	problems []LineError
+	lines    map[string]int
+}
+
+func (c *leagueChecker) add(line int, p Player) {
+	if err := validatePlayerName(p.Name); err != nil {
+		c.fail(line, err.Error())
+		return
+	}
+	if p.Wins < 0 {
+		c.fail(line, fmt.Sprintf("wins must not be negative, got %d", p.Wins))
+		return
+	}
+	if p.Wins > maxImportWins {
+		c.fail(line, fmt.Sprintf("wins must be at most %d, got %d", maxImportWins, p.Wins))
+		return
+	}
+	if first, ok := c.lines[p.Name]; ok {
+		c.fail(line, fmt.Sprintf("player %q is already on line %d", p.Name, first))
+		return
+	}
+
+	if c.lines == nil {
+		c.lines = map[string]int{}
+	}
+	c.lines[p.Name] = line
+	c.league = append(c.league, p)
+}
+
+func (c *leagueChecker) fail(line int, message string) {
+	if c.full() {
+		return
+	}
+	c.problems = append(c.problems, LineError{line, message})
+	if c.full() {
+		c.problems = append(c.problems, LineError{line, "too many errors, stopped checking"})
+	}
+}
+
+func (c *leagueChecker) full() bool {
+	return len(c.problems) >= maxImportErrors
+}
+
+func (c *leagueChecker) result() (League, []LineError, error) {
+	if len(c.problems) > 0 {
+		return nil, c.problems, nil
+	}
+	if c.league == nil {
+		return League{}, nil, nil
+	}
+	return c.league, nil, nil
+}
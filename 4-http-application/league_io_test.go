@@ -0,0 +1,169 @@
+// league_io_test.go
+package poker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReadLeagueCSV(t *testing.T) {
+
+	t.Run("reads players after the header", func(t *testing.T) {
+		league, problems, err := readLeagueCSV(strings.NewReader("Name, Wins\nCleo,32\n\"Chris, Jr.\", 20\n"))
+
+		assertNoError(t, err)
+		assertErrorLines(t, problems)
+		assertLeague(t, league, League{{"Cleo", 32}, {"Chris, Jr.", 20}})
+	})
+
+	t.Run("a header on its own is an empty league", func(t *testing.T) {
+		league, problems, err := readLeagueCSV(strings.NewReader("name,wins\n"))
+
+		assertNoError(t, err)
+		assertErrorLines(t, problems)
+		assertLeague(t, league, League{})
+	})
+
+	t.Run("needs the header", func(t *testing.T) {
+		_, problems, _ := readLeagueCSV(strings.NewReader("Cleo,32\n"))
+		assertErrorLines(t, problems, 1)
+
+		_, problems, _ = readLeagueCSV(strings.NewReader(""))
+		assertErrorLines(t, problems, 1)
+	})
+
+	t.Run("reports each bad row with its line", func(t *testing.T) {
+		input := strings.Join([]string{
+			"name,wins",
+			"Cleo,32",
+			"Chris",
+			"Tiest,-1",
+			"a/b,1",
+			"Cleo,4",
+			"Alice,1,extra",
+			"Bob,1.5",
+			"Dave,2000000000",
+		}, "\n")
+
+		league, problems, err := readLeagueCSV(strings.NewReader(input))
+
+		assertNoError(t, err)
+		assertErrorLines(t, problems, 3, 4, 5, 6, 7, 8, 9)
+		if league != nil {
+			t.Errorf("got league %v alongside problems", league)
+		}
+	})
+
+	t.Run("reports broken quoting", func(t *testing.T) {
+		_, problems, _ := readLeagueCSV(strings.NewReader("name,wins\nCleo,32\n\"Chris,20\n"))
+
+		if len(problems) != 1 {
+			t.Fatalf("got problems %v want one", problems)
+		}
+	})
+
+	t.Run("stops after too many problems", func(t *testing.T) {
+		input := "name,wins\n" + strings.Repeat("Cleo,many\n", 2*maxImportErrors)
+
+		_, problems, _ := readLeagueCSV(strings.NewReader(input))
+
+		if len(problems) != maxImportErrors+1 {
+			t.Errorf("got %d problems want %d", len(problems), maxImportErrors+1)
+		}
+	})
+}
+
+func TestReadLeagueNDJSON(t *testing.T) {
+
+	t.Run("reads one player a line, skipping blank lines", func(t *testing.T) {
+		input := `{"Name":"Cleo","Wins":32}` + "\n\n" + `{"name":"Chris","wins":0}` + "\n"
+
+		league, problems, err := readLeagueNDJSON(strings.NewReader(input))
+
+		assertNoError(t, err)
+		assertErrorLines(t, problems)
+		assertLeague(t, league, League{{"Cleo", 32}, {"Chris", 0}})
+	})
+
+	t.Run("an empty file is an empty league", func(t *testing.T) {
+		league, problems, err := readLeagueNDJSON(strings.NewReader(""))
+
+		assertNoError(t, err)
+		assertErrorLines(t, problems)
+		assertLeague(t, league, League{})
+	})
+
+	t.Run("reports each bad line with its number", func(t *testing.T) {
+		input := strings.Join([]string{
+			`{"Name":"Cleo","Wins":32}`,
+			`{"Name":"Chris"}`,
+			`{"Name":"Tiest","Wins":1,"Rating":1500}`,
+			`not json`,
+			`{"Name":"Alice","Wins":1} {"Name":"Bob","Wins":1}`,
+			``,
+			`{"Name":"Cleo","Wins":2}`,
+			`{"Name":"","Wins":2}`,
+			`{"Name":"x","Wins":2000000000}`,
+		}, "\n")
+
+		league, problems, err := readLeagueNDJSON(strings.NewReader(input))
+
+		assertNoError(t, err)
+		assertErrorLines(t, problems, 2, 3, 4, 5, 7, 8, 9)
+		if league != nil {
+			t.Errorf("got league %v alongside problems", league)
+		}
+	})
+
+	t.Run("reports a line that is too long", func(t *testing.T) {
+		input := `{"Name":"Cleo","Wins":32}` + "\n" + strings.Repeat(" ", maxNDJSONLine+1)
+
+		_, problems, err := readLeagueNDJSON(strings.NewReader(input))
+
+		assertNoError(t, err)
+		assertErrorLines(t, problems, 2)
+	})
+}
+
+func TestNegotiateLeagueFormat(t *testing.T) {
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", "ndjson"},
+		{"*/*", "ndjson"},
+		{"text/csv", "csv"},
+		{"text/*", "csv"},
+		{"application/ndjson", "ndjson"},
+		{"text/html, text/csv;q=0.8", "csv"},
+		{"text/csv;q=0.2, */*;q=0.5", "ndjson"},
+		{"text/csv;q=0", ""},
+		{"application/json", ""},
+	}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("Accept %q", c.accept), func(t *testing.T) {
+			format, ok := negotiateLeagueFormat(c.accept)
+
+			if got := format.name; got != c.want || ok != (c.want != "") {
+				t.Errorf("got %q, %v want %q", got, ok, c.want)
+			}
+		})
+	}
+}
+
+func assertErrorLines(t testing.TB, got []LineError, wantLines ...int) {
+	t.Helper()
+
+	lines := []int{}
+	for _, problem := range got {
+		lines = append(lines, problem.Line)
+		if problem.Error == "" {
+			t.Errorf("line %d has no error message", problem.Line)
+		}
+	}
+	if fmt.Sprint(lines) != fmt.Sprint(wantLines) && !(len(lines) == 0 && len(wantLines) == 0) {
+		t.Errorf("got problems on lines %v want %v, %v", lines, wantLines, got)
+	}
+}
@@ -0,0 +1,10 @@
+// lock_other.go
+//go:build !unix
+
+package poker
+
+// lockFile can't lock across processes here, so writers from different
+// processes can still overwrite each other's changes.
+func lockFile(string) (func(), error) {
+	return func() {}, nil
+}
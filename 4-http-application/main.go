@@ -1,12 +0,0 @@
-// main.go
-package main
-
-import (
-	"log"
-	"net/http"
-)
-
-func main() {
-	server := &PlayerServer{NewInMemoryPlayerStore()}
-	log.Fatal(http.ListenAndServe(":5000", server))
-}
@@ -0,0 +1,127 @@
+// player_server_ws.go
+package poker
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// playerServerWS is a websocket connection that a Game can write blind alerts
+// to. Alerts fire from their own goroutines and a websocket only allows one
+// writer at a time, hence the lock.
+type playerServerWS struct {
+	mu sync.Mutex
+	*websocket.Conn
+}
+
+func newPlayerServerWS(w http.ResponseWriter, r *http.Request) (*playerServerWS, error) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &playerServerWS{Conn: conn}, nil
+}
+
+// WaitForMsg blocks until the browser sends a message. ok is false once the
+// connection has gone away.
+func (w *playerServerWS) WaitForMsg() (msg string, ok bool) {
+	_, data, err := w.ReadMessage()
+	if err != nil {
+		if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+			log.Printf("error reading from websocket %v\n", err)
+		}
+		return "", false
+	}
+	return string(data), true
+}
+
+// closeWithError tells the browser the server failed, the websocket's
+// equivalent of a 500, and closes the connection.
+func (w *playerServerWS) closeWithError(reason string) {
+	w.closeWith(websocket.CloseInternalServerErr, reason)
+}
+
+func (w *playerServerWS) closeWith(code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	if err := w.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second)); err != nil {
+		log.Printf("problem closing websocket %v\n", err)
+	}
+}
+
+func (w *playerServerWS) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err = w.WriteMessage(websocket.TextMessage, p)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// gameTracker keeps count of the websocket games that are running.
+// http.Server.Shutdown can't see them once their connections have been
+// hijacked, so the server has to stop and wait for them itself.
+type gameTracker struct {
+	mu       sync.Mutex
+	running  sync.WaitGroup
+	stopping chan struct{}
+	stopped  bool
+}
+
+func newGameTracker() *gameTracker {
+	return &gameTracker{stopping: make(chan struct{})}
+}
+
+// start counts a new game in, unless the tracker has been stopped. Every
+// successful start must be matched by a call to done.
+func (g *gameTracker) start() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopped {
+		return false
+	}
+	g.running.Add(1)
+	return true
+}
+
+func (g *gameTracker) done() {
+	g.running.Done()
+}
+
+// closeOnStop closes ws if the tracker is stopped before the returned func
+// is called, so a game waiting on the browser gives up.
+func (g *gameTracker) closeOnStop(ws *playerServerWS) func() {
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-g.stopping:
+			ws.closeWith(websocket.CloseGoingAway, "server is shutting down")
+			ws.Close()
+		case <-finished:
+		}
+	}()
+	return func() { close(finished) }
+}
+
+func (g *gameTracker) stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.stopped {
+		g.stopped = true
+		close(g.stopping)
+	}
+}
+
+func (g *gameTracker) wait() {
+	g.running.Wait()
+}
@@ -0,0 +1,44 @@
+// player_store_contract_test.go
+package poker_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	poker "oop-lectures/4-http-application"
+	"oop-lectures/4-http-application/playerstoretest"
+)
+
+func TestPlayerStoreContract(t *testing.T) {
+	t.Run("InMemoryPlayerStore", func(t *testing.T) {
+		playerstoretest.PlayerStoreContract(t, func(t *testing.T, clock poker.Clock) poker.PlayerStore {
+			return poker.NewInMemoryPlayerStore(poker.WithClock(clock))
+		})
+	})
+
+	t.Run("FileSystemPlayerStore", func(t *testing.T) {
+		playerstoretest.PlayerStoreContract(t, func(t *testing.T, clock poker.Clock) poker.PlayerStore {
+			return mustOpen(t, poker.FileSystemPlayerStoreFromFile, clock)
+		})
+	})
+
+	t.Run("SQLPlayerStore", func(t *testing.T) {
+		playerstoretest.PlayerStoreContract(t, func(t *testing.T, clock poker.Clock) poker.PlayerStore {
+			return mustOpen(t, poker.SQLPlayerStoreFromFile, clock)
+		})
+	})
+}
+
+// mustOpen opens a store on a new file in a temporary directory and closes
+// it when the test is done.
+func mustOpen[S poker.PlayerStore](t *testing.T, open func(string, ...poker.StoreOption) (S, func(), error), clock poker.Clock) S {
+	t.Helper()
+
+	store, closeStore, err := open(filepath.Join(t.TempDir(), "league"), poker.WithClock(clock))
+	if err != nil {
+		t.Fatalf("could not open store, %v", err)
+	}
+	t.Cleanup(closeStore)
+
+	return store
+}
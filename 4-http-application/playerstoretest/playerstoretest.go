@@ -0,0 +1,320 @@
+// Package playerstoretest is the test suite for poker.PlayerStore
+// implementations. It is kept out of package poker so the binaries that
+// import poker don't link in package testing.
+package playerstoretest
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	poker "oop-lectures/4-http-application"
+)
+
+// PlayerStoreContract runs the behaviour every PlayerStore has to share
+// against the store returned by newStore. newStore is called once per subtest
+// and must hand back an empty store each time that stamps wins with clock;
+// a nil clock means the real time. Register any clean up with t.Cleanup.
+//
+//	func TestMyStore(t *testing.T) {
+//		playerstoretest.PlayerStoreContract(t, func(t *testing.T, clock poker.Clock) poker.PlayerStore {
+//			return NewMyStore(poker.WithClock(clock))
+//		})
+//	}
+func PlayerStoreContract(t *testing.T, newStore func(t *testing.T, clock poker.Clock) poker.PlayerStore) {
+	t.Helper()
+
+	t.Run("unknown players have no score", func(t *testing.T) {
+		store := newStore(t, nil)
+
+		contractScore(t, store, "Pepper", 0, false)
+	})
+
+	t.Run("recording wins increments the score", func(t *testing.T) {
+		store := newStore(t, nil)
+
+		contractNoError(t, store.RecordWin("Pepper"))
+		contractScore(t, store, "Pepper", 1, true)
+
+		contractNoError(t, store.RecordWin("Pepper"))
+		contractNoError(t, store.RecordGameWin("Pepper", "friday"))
+		contractScore(t, store, "Pepper", 3, true)
+	})
+
+	t.Run("keeps many players apart", func(t *testing.T) {
+		store := newStore(t, nil)
+
+		for i := 0; i < 50; i++ {
+			for j := 0; j <= i%5; j++ {
+				store.RecordWin(fmt.Sprintf("player-%d", i))
+			}
+		}
+
+		for i := 0; i < 50; i++ {
+			contractScore(t, store, fmt.Sprintf("player-%d", i), i%5+1, true)
+		}
+		if got := len(store.GetLeague()); got != 50 {
+			t.Errorf("got %d players in the league want %d", got, 50)
+		}
+	})
+
+	t.Run("records concurrent wins", func(t *testing.T) {
+		store := newStore(t, nil)
+		wantedCount := 100
+		players := []string{"Pepper", "Cleo"}
+
+		var wg sync.WaitGroup
+		wg.Add(wantedCount * len(players))
+		for i := 0; i < wantedCount; i++ {
+			for _, player := range players {
+				go func(name string) {
+					defer wg.Done()
+					store.RecordWin(name)
+				}(player)
+			}
+		}
+		wg.Wait()
+
+		for _, player := range players {
+			contractScore(t, store, player, wantedCount, true)
+		}
+	})
+
+	t.Run("league is sorted by wins then name", func(t *testing.T) {
+		store := newStore(t, nil)
+
+		store.RecordWin("Cleo")
+		store.RecordWin("Chris")
+		store.RecordWin("Chris")
+		store.RecordWin("Alice")
+
+		want := poker.League{
+			{Name: "Chris", Wins: 2},
+			{Name: "Alice", Wins: 1},
+			{Name: "Cleo", Wins: 1},
+		}
+		if got := store.GetLeague(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got league %v want %v", got, want)
+		}
+	})
+
+	t.Run("history has one entry per win", func(t *testing.T) {
+		store := newStore(t, nil)
+
+		store.RecordGameWin("Pepper", "friday")
+		store.RecordWin("Pepper")
+
+		history := store.GetPlayerHistory("Pepper", time.Time{}, time.Time{}).Wins
+		if len(history) != 2 {
+			t.Fatalf("got %d wins in history want %d, %v", len(history), 2, history)
+		}
+		if history[0].GameID != "friday" || history[1].GameID != "" {
+			t.Errorf("got game IDs %q and %q want %q and %q", history[0].GameID, history[1].GameID, "friday", "")
+		}
+		if history[1].At.Before(history[0].At) {
+			t.Errorf("history is not oldest first, %v", history)
+		}
+		contractScore(t, store, "Pepper", 2, true)
+	})
+
+	t.Run("history filters by time", func(t *testing.T) {
+		store := newStore(t, nil)
+
+		store.RecordWin("Pepper")
+
+		future := time.Now().Add(time.Hour)
+		if got := store.GetPlayerHistory("Pepper", future, time.Time{}); got.Len() != 0 {
+			t.Errorf("got %v wins from the future", got)
+		}
+		if got := store.GetPlayerHistory("Pepper", time.Time{}, future); got.Len() != 1 {
+			t.Errorf("got %d wins before %v want 1", got.Len(), future)
+		}
+	})
+
+	t.Run("history stamps each win with the clock", func(t *testing.T) {
+		store := newStore(t, StubClock(GameNight, time.Hour))
+
+		store.RecordGameWin("Pepper", "g1")
+		store.RecordWin("Cleo")
+		store.RecordGameWin("Pepper", "g2")
+
+		want := []poker.Win{
+			{At: GameNight, GameID: "g1"},
+			{At: GameNight.Add(2 * time.Hour), GameID: "g2"},
+		}
+		AssertHistory(t, store.GetPlayerHistory("Pepper", time.Time{}, time.Time{}), 0, want)
+		contractScore(t, store, "Pepper", 2, true)
+		contractScore(t, store, "Cleo", 1, true)
+	})
+
+	t.Run("history is from inclusive and to exclusive", func(t *testing.T) {
+		store := newStore(t, StubClock(GameNight, time.Hour))
+
+		store.RecordGameWin("Pepper", "g1")
+		store.RecordGameWin("Pepper", "g2")
+		store.RecordGameWin("Pepper", "g3")
+
+		got := store.GetPlayerHistory("Pepper", GameNight.Add(time.Hour), GameNight.Add(2*time.Hour))
+		AssertHistory(t, got, 0, []poker.Win{{At: GameNight.Add(time.Hour), GameID: "g2"}})
+	})
+
+	t.Run("unknown players have an empty history", func(t *testing.T) {
+		store := newStore(t, nil)
+
+		got := store.GetPlayerHistory("Pepper", time.Time{}, time.Time{})
+		if got.Wins == nil || got.Len() != 0 {
+			t.Errorf("got history %#v want an empty one", got)
+		}
+	})
+
+	t.Run("importing merges scores into the league", func(t *testing.T) {
+		store := newStore(t, nil)
+
+		store.RecordWin("Pepper")
+		store.RecordWin("Cleo")
+
+		err := store.ImportLeague(poker.League{{Name: "Cleo", Wins: 5}, {Name: "Chris", Wins: 3}, {Name: "Alice", Wins: 0}}, false)
+		contractNoError(t, err)
+
+		contractScore(t, store, "Pepper", 1, true)
+		contractScore(t, store, "Cleo", 5, true)
+		contractScore(t, store, "Chris", 3, true)
+		contractScore(t, store, "Alice", 0, true)
+	})
+
+	t.Run("importing with replace drops everyone else", func(t *testing.T) {
+		store := newStore(t, nil)
+
+		store.RecordWin("Pepper")
+		store.RecordWin("Cleo")
+
+		err := store.ImportLeague(poker.League{{Name: "Cleo", Wins: 2}}, true)
+		contractNoError(t, err)
+
+		contractScore(t, store, "Pepper", 0, false)
+		contractScore(t, store, "Cleo", 2, true)
+		want := poker.League{{Name: "Cleo", Wins: 2}}
+		if got := store.GetLeague(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got league %v want %v", got, want)
+		}
+		if got := store.GetPlayerHistory("Pepper", time.Time{}, time.Time{}); got.Len() != 0 {
+			t.Errorf("got history %v for a removed player", got)
+		}
+	})
+
+	t.Run("importing a lower score keeps the newest wins", func(t *testing.T) {
+		store := newStore(t, nil)
+
+		store.RecordGameWin("Pepper", "first")
+		store.RecordGameWin("Pepper", "second")
+		store.RecordGameWin("Pepper", "third")
+
+		contractNoError(t, store.ImportLeague(poker.League{{Name: "Pepper", Wins: 2}}, false))
+
+		history := store.GetPlayerHistory("Pepper", time.Time{}, time.Time{})
+		if history.Undated != 0 || len(history.Wins) != 2 || history.Wins[0].GameID != "second" || history.Wins[1].GameID != "third" {
+			t.Errorf("got history %v want the second and third games", history)
+		}
+		contractScore(t, store, "Pepper", 2, true)
+	})
+
+	t.Run("importing a higher score adds undated wins", func(t *testing.T) {
+		store := newStore(t, nil)
+
+		store.RecordGameWin("Pepper", "friday")
+
+		contractNoError(t, store.ImportLeague(poker.League{{Name: "Pepper", Wins: 3}}, false))
+
+		history := store.GetPlayerHistory("Pepper", time.Time{}, time.Time{})
+		if history.Undated != 2 || len(history.Wins) != 1 || history.Wins[0].GameID != "friday" {
+			t.Errorf("got history %v want two undated wins before friday's", history)
+		}
+		contractScore(t, store, "Pepper", 3, true)
+	})
+
+	t.Run("importing a huge score only counts it", func(t *testing.T) {
+		store := newStore(t, nil)
+
+		contractNoError(t, store.ImportLeague(poker.League{{Name: "Pepper", Wins: 2_000_000_000}}, false))
+		store.RecordWin("Pepper")
+
+		history := store.GetPlayerHistory("Pepper", time.Time{}, time.Time{})
+		if history.Undated != 2_000_000_000 || len(history.Wins) != 1 {
+			t.Errorf("got %d undated and %d dated wins want 2000000000 and 1", history.Undated, len(history.Wins))
+		}
+		contractScore(t, store, "Pepper", 2_000_000_001, true)
+	})
+
+	t.Run("importing a lower score drops undated wins first", func(t *testing.T) {
+		store := newStore(t, nil)
+
+		store.RecordGameWin("Pepper", "friday")
+		contractNoError(t, store.ImportLeague(poker.League{{Name: "Pepper", Wins: 4}}, false))
+		contractNoError(t, store.ImportLeague(poker.League{{Name: "Pepper", Wins: 2}}, false))
+
+		history := store.GetPlayerHistory("Pepper", time.Time{}, time.Time{})
+		if history.Undated != 1 || len(history.Wins) != 1 || history.Wins[0].GameID != "friday" {
+			t.Errorf("got history %v want one undated win before friday's", history)
+		}
+	})
+
+	t.Run("empty league is empty, not nil", func(t *testing.T) {
+		store := newStore(t, nil)
+
+		got := store.GetLeague()
+		if got == nil || len(got) != 0 {
+			t.Errorf("got league %#v want an empty League", got)
+		}
+	})
+}
+
+// GameNight is a fixed time for a StubClock to start from.
+var GameNight = time.Date(2026, time.October, 9, 20, 0, 0, 0, time.UTC)
+
+// StubClock starts at start and moves on by step every time it is read.
+func StubClock(start time.Time, step time.Duration) poker.Clock {
+	now := start.Add(-step)
+	return func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+}
+
+// AssertHistory checks got has wantUndated undated wins and the dated wins
+// in want, in order.
+func AssertHistory(t testing.TB, got poker.History, wantUndated int, want []poker.Win) {
+	t.Helper()
+	if got.Undated != wantUndated {
+		t.Errorf("got %d undated wins want %d", got.Undated, wantUndated)
+	}
+	if len(got.Wins) != len(want) {
+		t.Fatalf("got %d wins %v want %d %v", len(got.Wins), got.Wins, len(want), want)
+	}
+	for i := range want {
+		if !got.Wins[i].At.Equal(want[i].At) || got.Wins[i].GameID != want[i].GameID {
+			t.Errorf("win %d: got %v want %v", i, got.Wins[i], want[i])
+		}
+	}
+}
+
+func contractNoError(t testing.TB, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("didn't expect an error but got one, %v", err)
+	}
+}
+
+func contractScore(t testing.TB, store poker.PlayerStore, name string, wantScore int, wantFound bool) {
+	t.Helper()
+
+	if got := store.GetPlayerScore(name); got != wantScore {
+		t.Errorf("GetPlayerScore(%q) = %d want %d", name, got, wantScore)
+	}
+
+	score, found := store.LookupPlayerScore(name)
+	if score != wantScore || found != wantFound {
+		t.Errorf("LookupPlayerScore(%q) = %d, %v want %d, %v", name, score, found, wantScore, wantFound)
+	}
+}
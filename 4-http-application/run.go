@@ -0,0 +1,134 @@
+// run.go
+package poker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+)
+
+// Run opens the store cfg asks for and serves the poker webserver until ctx
+// is done. It then stops taking new connections and gives in-flight requests
+// up to cfg.ShutdownTimeout to finish before closing the store.
+func Run(ctx context.Context, cfg Config) error {
+	return run(ctx, cfg, nil)
+}
+
+// run is Run with a hook that is told where the server ended up listening,
+// which is how tests find a server started on port 0.
+func run(ctx context.Context, cfg Config, listening func(net.Addr)) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	store, closeStore, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	handler, players, err := newHandler(cfg, store)
+	if err != nil {
+		return err
+	}
+	// Shutdown doesn't wait for the websocket games, so wait for them here,
+	// before the store they finish into is closed
+	defer players.WaitForGames()
+	defer players.StopGames()
+
+	return serve(ctx, cfg, handler, players.StopGames, listening)
+}
+
+func openStore(cfg Config) (PlayerStore, func(), error) {
+	switch cfg.Store {
+	case "sqlite":
+		return SQLPlayerStoreFromFile(cfg.dbPath())
+	case "memory":
+		return NewInMemoryPlayerStore(), func() {}, nil
+	default:
+		return FileSystemPlayerStoreFromFile(cfg.dbPath())
+	}
+}
+
+// openRatings keeps the ratings in a file next to the league, so they
+// survive a restart along with the wins. With the memory store there is
+// nowhere to put them and they go when the process does.
+func openRatings(cfg Config) (Ratings, error) {
+	if cfg.Store == "memory" {
+		return NewEloRatings(cfg.KFactor), nil
+	}
+	return EloRatingsFromFile(cfg.ratingsPath(), cfg.KFactor)
+}
+
+// newHandler wraps the PlayerServer in the auth and metrics middleware. The
+// PlayerServer is returned as well so its games can be stopped on shutdown.
+func newHandler(cfg Config, store PlayerStore) (http.Handler, *PlayerServer, error) {
+	game := NewTexasHoldem(BlindAlerterFunc(Alerter), store)
+
+	ratings, err := openRatings(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	players := NewPlayerServer(store, game, ratings)
+	var handler http.Handler = players
+	if cfg.AuthConfigPath != "" {
+		authConfig, err := LoadAuthConfig(cfg.AuthConfigPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		handler = RequireAuth(handler, authConfig.Authenticator())
+	}
+	return Instrument(handler, store), players, nil
+}
+
+// serve runs handler until ctx is done and then shuts down, calling
+// onShutdown, if there is one, as the shutdown starts.
+func serve(ctx context.Context, cfg Config, handler http.Handler, onShutdown func(), listening func(net.Addr)) error {
+	listener, err := net.Listen("tcp", cfg.Addr())
+	if err != nil {
+		return fmt.Errorf("could not listen on %s %v", cfg.Addr(), err)
+	}
+
+	server := &http.Server{
+		Handler:      handler,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+	if onShutdown != nil {
+		server.RegisterOnShutdown(onShutdown)
+	}
+
+	log.Printf("listening on %s", listener.Addr())
+	if listening != nil {
+		listening(listener.Addr())
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("shutting down, waiting up to %v for requests to finish", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return fmt.Errorf("could not shut down cleanly, %v", err)
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+// run_test.go
+package poker
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func testConfig(t testing.TB) Config {
+	cfg := DefaultConfig()
+	cfg.Host = "127.0.0.1"
+	cfg.Port = 0
+	cfg.Store = "file"
+	cfg.DBPath = filepath.Join(t.TempDir(), DBFileName)
+	cfg.ShutdownTimeout = time.Second
+	return cfg
+}
+
+// startRun runs the webserver in the background and returns its base URL
+// and a channel that gets Run's result once ctx is cancelled.
+func startRun(t *testing.T, ctx context.Context, cfg Config) (string, <-chan error) {
+	t.Helper()
+
+	addrs := make(chan net.Addr, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx, cfg, func(addr net.Addr) { addrs <- addr })
+	}()
+
+	select {
+	case addr := <-addrs:
+		return "http://" + addr.String(), done
+	case err := <-done:
+		t.Fatalf("server stopped before it started listening, %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the server to listen")
+	}
+	return "", nil
+}
+
+func TestRun(t *testing.T) {
+
+	t.Run("serves the league until the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		url, done := startRun(t, ctx, testConfig(t))
+
+		response, err := http.Post(url+"/players/Pepper", "", nil)
+		assertNoError(t, err)
+		response.Body.Close()
+		assertStatus(t, response.StatusCode, http.StatusAccepted)
+
+		response, err = http.Get(url + "/players/Pepper")
+		assertNoError(t, err)
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		assertResponseBody(t, string(body), "1")
+
+		cancel()
+		assertRunStopped(t, done)
+
+		if _, err := http.Get(url + "/league"); err == nil {
+			t.Error("server still answering after shutdown")
+		}
+	})
+
+	t.Run("keeps the league between runs", func(t *testing.T) {
+		cfg := testConfig(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		url, done := startRun(t, ctx, cfg)
+		response, err := http.Post(url+"/players/Pepper", "", nil)
+		assertNoError(t, err)
+		response.Body.Close()
+		cancel()
+		assertRunStopped(t, done)
+
+		ctx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+		url, done = startRun(t, ctx, cfg)
+
+		response, err = http.Get(url + "/players/Pepper")
+		assertNoError(t, err)
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		assertResponseBody(t, string(body), "1")
+
+		cancel()
+		assertRunStopped(t, done)
+	})
+
+	t.Run("keeps the ratings between runs", func(t *testing.T) {
+		cfg := testConfig(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		url, done := startRun(t, ctx, cfg)
+		response, err := http.Post(url+"/matches", jsonContentType, strings.NewReader(`{"winner":"Pepper","losers":["Cleo"]}`))
+		assertNoError(t, err)
+		response.Body.Close()
+		assertStatus(t, response.StatusCode, http.StatusAccepted)
+		cancel()
+		assertRunStopped(t, done)
+
+		ctx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+		url, done = startRun(t, ctx, cfg)
+
+		response, err = http.Get(url + "/players/Pepper/rating")
+		assertNoError(t, err)
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		if !strings.Contains(string(body), "1516") {
+			t.Errorf("got rating %q want Pepper still on 1516", body)
+		}
+
+		cancel()
+		assertRunStopped(t, done)
+	})
+
+	t.Run("stops websocket games before it returns", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		url, done := startRun(t, ctx, testConfig(t))
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(url, "http")+"/ws")
+		defer ws.Close()
+		writeWSMessage(t, ws, "3")
+
+		cancel()
+		assertRunStopped(t, done)
+		assertWebsocketClosedWith(t, ws, websocket.CloseGoingAway)
+	})
+
+	t.Run("serves metrics", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		cfg := testConfig(t)
+		cfg.Store = "memory"
+
+		url, done := startRun(t, ctx, cfg)
+
+		response, err := http.Get(url + "/metrics")
+		assertNoError(t, err)
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		if !strings.Contains(string(body), "poker_players 0") {
+			t.Errorf("got metrics %q", body)
+		}
+
+		cancel()
+		assertRunStopped(t, done)
+	})
+
+	t.Run("fails when the auth config is missing", func(t *testing.T) {
+		cfg := testConfig(t)
+		cfg.AuthConfigPath = filepath.Join(t.TempDir(), "missing.json")
+
+		if err := Run(context.Background(), cfg); err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+	})
+
+	t.Run("fails when the port is taken", func(t *testing.T) {
+		taken, err := net.Listen("tcp", "127.0.0.1:0")
+		assertNoError(t, err)
+		defer taken.Close()
+
+		cfg := testConfig(t)
+		cfg.Port = taken.Addr().(*net.TCPAddr).Port
+
+		if err := Run(context.Background(), cfg); err == nil {
+			t.Error("expected an error but didn't get one")
+		}
+	})
+}
+
+func TestServeDrainsOnShutdown(t *testing.T) {
+
+	t.Run("lets in-flight requests finish", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			io.WriteString(w, "finished")
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		cfg := testConfig(t)
+		cfg.ShutdownTimeout = 5 * time.Second
+
+		url, done := startServe(t, ctx, cfg, slow)
+
+		type result struct {
+			body string
+			err  error
+		}
+		responses := make(chan result, 1)
+		go func() {
+			response, err := http.Get(url)
+			if err != nil {
+				responses <- result{err: err}
+				return
+			}
+			defer response.Body.Close()
+			body, err := io.ReadAll(response.Body)
+			responses <- result{string(body), err}
+		}()
+
+		<-started
+		cancel()
+		// give Shutdown a moment to start before the request is let go
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+
+		got := <-responses
+		assertNoError(t, got.err)
+		assertResponseBody(t, got.body, "finished")
+		assertRunStopped(t, done)
+	})
+
+	t.Run("gives up after the shutdown timeout", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		stuck := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cfg := testConfig(t)
+		cfg.ShutdownTimeout = 50 * time.Millisecond
+
+		url, done := startServe(t, ctx, cfg, stuck)
+		go http.Get(url)
+
+		<-started
+		cancel()
+
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Error("expected an error from a shutdown that timed out")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("serve did not give up on the stuck request")
+		}
+	})
+}
+
+func startServe(t *testing.T, ctx context.Context, cfg Config, handler http.Handler) (string, <-chan error) {
+	t.Helper()
+
+	addrs := make(chan net.Addr, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, cfg, handler, nil, func(addr net.Addr) { addrs <- addr })
+	}()
+
+	return "http://" + (<-addrs).String(), done
+}
+
+func assertRunStopped(t testing.TB, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		assertNoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the server to stop")
+	}
+}
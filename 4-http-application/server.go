@@ -1,36 +1,438 @@
 // server.go
-package main
+package poker
 
 import (
+	_ "embed"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
+const jsonContentType = "application/json"
+const htmlContentType = "text/html; charset=utf-8"
+const historySuffix = "/history"
+const ratingSuffix = "/rating"
+
+// maxImportSize is the largest league /league/import will read.
+const maxImportSize = 10 << 20
+
+//go:embed game.html
+var gameHTML []byte
+
 type PlayerStore interface {
 	GetPlayerScore(name string) int
-	RecordWin(name string)
+	// LookupPlayerScore is like GetPlayerScore but also reports whether the
+	// store has ever heard of the player, so zero wins can be told apart
+	// from an unknown name.
+	LookupPlayerScore(name string) (int, bool)
+	// RecordWin adds a win for the player. If it returns an error the win
+	// was not saved.
+	RecordWin(name string) error
+	// RecordGameWin is RecordWin for a win in a known game; gameID may be
+	// empty.
+	RecordGameWin(name, gameID string) error
+	// GetPlayerHistory returns the player's wins, oldest first, at or after
+	// from and before to. A zero from or to leaves that end open.
+	GetPlayerHistory(name string, from, to time.Time) History
+	GetLeague() League
+	// ImportLeague sets the score of every player in league, all or nothing.
+	// With replace, players not in league are removed; otherwise they are
+	// left alone. Lowering a score drops the player's oldest wins and raising
+	// it adds undated ones.
+	ImportLeague(league League, replace bool) error
 }
 
 type PlayerServer struct {
-	store PlayerStore
+	store   PlayerStore
+	game    Game
+	ratings Ratings
+	games   *gameTracker
+	http.Handler
+}
+
+func NewPlayerServer(store PlayerStore, game Game, ratings Ratings) *PlayerServer {
+	p := new(PlayerServer)
+	p.store = store
+	p.game = game
+	p.ratings = ratings
+	p.games = newGameTracker()
+
+	router := http.NewServeMux()
+	router.Handle("/league", allowMethods(p.leagueHandler, http.MethodGet))
+	router.Handle("/league/export", allowMethods(p.exportLeagueHandler, http.MethodGet))
+	router.Handle("/league/import", allowMethods(p.importLeagueHandler, http.MethodPost))
+	router.Handle("/players/", p.playersRouter())
+	router.Handle("/matches", allowMethods(p.matchHandler, http.MethodPost))
+	router.Handle("/game", allowMethods(p.playGame, http.MethodGet))
+	router.Handle("/ws", http.HandlerFunc(p.webSocket))
+
+	p.Handler = router
+	return p
+}
+
+// leagueHandler lists the league by wins, or by rating with ?sort=rating.
+func (p *PlayerServer) leagueHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Query().Get("sort") {
+	case "", "wins":
+		w.Header().Set("content-type", jsonContentType)
+		json.NewEncoder(w).Encode(p.store.GetLeague())
+	case "rating":
+		w.Header().Set("content-type", jsonContentType)
+		json.NewEncoder(w).Encode(rateLeague(p.store.GetLeague(), p.ratings))
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("cannot sort league by %q, use wins or rating", r.URL.Query().Get("sort")))
+	}
+}
+
+// exportLeagueHandler writes the league one player per line, as NDJSON or CSV
+// depending on the Accept header.
+func (p *PlayerServer) exportLeagueHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	format, ok := negotiateLeagueFormat(r.Header.Get("Accept"))
+	if !ok {
+		writeError(w, http.StatusNotAcceptable, fmt.Sprintf("cannot export the league as %q, accept %s or %s", r.Header.Get("Accept"), ndjsonContentType, "text/csv"))
+		return
+	}
+
+	w.Header().Set("content-type", format.contentType)
+	w.Header().Set("content-disposition", fmt.Sprintf("attachment; filename=%q", "league."+format.name))
+	if err := format.write(w, p.store.GetLeague()); err != nil {
+		log.Printf("problem exporting league, %v", err)
+	}
+}
+
+// LeagueImport is the body of a successful /league/import.
+type LeagueImport struct {
+	Mode    string `json:"mode"`
+	Players int    `json:"players"`
+}
+
+// ImportErrorResponse is sent when an import is rejected, with every problem
+// found in the file.
+type ImportErrorResponse struct {
+	ErrorResponse
+	Lines []LineError `json:"lines"`
+}
+
+// importLeagueHandler reads a whole league in the format named by the
+// Content-Type and only touches the store if every line is good. With
+// ?mode=replace the league becomes exactly the file; the default, merge,
+// leaves players who aren't in it alone.
+func (p *PlayerServer) importLeagueHandler(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "merge"
+	}
+	if mode != "merge" && mode != "replace" {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("cannot import with mode %q, use merge or replace", mode))
+		return
+	}
+
+	format, ok := leagueFormatOf(r.Header.Get("content-type"))
+	if !ok {
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Sprintf("cannot import %q, send %s or %s", r.Header.Get("content-type"), ndjsonContentType, "text/csv"))
+		return
+	}
+
+	league, problems, err := format.read(http.MaxBytesReader(w, r.Body, maxImportSize))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("league must be at most %d bytes", tooLarge.Limit))
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("could not read league, %v", err))
+		return
+	case len(problems) > 0:
+		w.Header().Set("content-type", jsonContentType)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ImportErrorResponse{
+			ErrorResponse: ErrorResponse{
+				Status: http.StatusBadRequest,
+				Error:  "the league has problems, nothing was imported",
+			},
+			Lines: problems,
+		})
+		return
+	}
+
+	if err := p.store.ImportLeague(league, mode == "replace"); err != nil {
+		log.Printf("problem importing league, %v", err)
+		writeError(w, http.StatusInternalServerError, "could not save the imported league")
+		return
+	}
+
+	w.Header().Set("content-type", jsonContentType)
+	json.NewEncoder(w).Encode(LeagueImport{Mode: mode, Players: len(league)})
 }
 
-func (p *PlayerServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+// playersRouter sends /players/{name}/history and /players/{name}/rating to
+// their handlers and everything else under /players/ to the score handler.
+func (p *PlayerServer) playersRouter() http.Handler {
+	scores := allowMethods(p.playersHandler, http.MethodGet, http.MethodPost)
+	history := allowMethods(p.historyHandler, http.MethodGet)
+	rating := allowMethods(p.ratingHandler, http.MethodGet)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, "/players/")
+		switch {
+		case strings.HasSuffix(rest, historySuffix):
+			history.ServeHTTP(w, r)
+		case strings.HasSuffix(rest, ratingSuffix):
+			rating.ServeHTTP(w, r)
+		default:
+			scores.ServeHTTP(w, r)
+		}
+	})
+}
+
+func (p *PlayerServer) playersHandler(w http.ResponseWriter, r *http.Request) {
 	player := strings.TrimPrefix(r.URL.Path, "/players/")
+	if err := validatePlayerName(player); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	switch r.Method {
 	case http.MethodPost:
-		p.store.RecordWin(player)
-		w.WriteHeader(http.StatusAccepted)
+		p.processWin(w, player, r.URL.Query().Get("game"))
 	case http.MethodGet:
 		p.showScore(w, player)
 	}
 }
 
 func (p *PlayerServer) showScore(w http.ResponseWriter, player string) {
-	score := p.store.GetPlayerScore(player)
-	if score == 0 {
-		w.WriteHeader(http.StatusNotFound)
+	score, ok := p.store.LookupPlayerScore(player)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("player %q not found", player))
+		return
 	}
 	fmt.Fprint(w, score)
 }
+
+func (p *PlayerServer) processWin(w http.ResponseWriter, player, gameID string) {
+	if err := p.store.RecordGameWin(player, gameID); err != nil {
+		log.Printf("problem recording win, %v", err)
+		writeError(w, http.StatusInternalServerError, "could not save the win")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (p *PlayerServer) historyHandler(w http.ResponseWriter, r *http.Request) {
+	player := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/players/"), historySuffix)
+	if err := validatePlayerName(player); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	from, err := parseTimeParam(r, "from")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	to, err := parseTimeParam(r, "to")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, ok := p.store.LookupPlayerScore(player); !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("player %q not found", player))
+		return
+	}
+
+	w.Header().Set("content-type", jsonContentType)
+	json.NewEncoder(w).Encode(p.store.GetPlayerHistory(player, from, to))
+}
+
+// PlayerRating is the body of /players/{name}/rating.
+type PlayerRating struct {
+	Name   string
+	Rating float64
+}
+
+func (p *PlayerServer) ratingHandler(w http.ResponseWriter, r *http.Request) {
+	player := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/players/"), ratingSuffix)
+	if err := validatePlayerName(player); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rating, rated := p.ratings.GetRating(player)
+	if _, known := p.store.LookupPlayerScore(player); !known && !rated {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("player %q not found", player))
+		return
+	}
+
+	w.Header().Set("content-type", jsonContentType)
+	json.NewEncoder(w).Encode(PlayerRating{player, rating})
+}
+
+// MatchResult is the body POSTed to /matches.
+type MatchResult struct {
+	Winner string
+	Losers []string
+	GameID string `json:",omitempty"`
+}
+
+func (m MatchResult) validate() error {
+	if err := validatePlayerName(m.Winner); err != nil {
+		return fmt.Errorf("winner: %v", err)
+	}
+	if len(m.Losers) == 0 {
+		return errors.New("a match needs at least one loser")
+	}
+
+	seen := map[string]bool{m.Winner: true}
+	for _, loser := range m.Losers {
+		if err := validatePlayerName(loser); err != nil {
+			return fmt.Errorf("loser: %v", err)
+		}
+		if seen[loser] {
+			return fmt.Errorf("%q appears more than once in the match", loser)
+		}
+		seen[loser] = true
+	}
+	return nil
+}
+
+// matchHandler records a win for the winner and moves everyone's rating. It
+// replies with the new ratings, winner first.
+func (p *PlayerServer) matchHandler(w http.ResponseWriter, r *http.Request) {
+	var match MatchResult
+	if err := json.NewDecoder(r.Body).Decode(&match); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("could not parse match result, %v", err))
+		return
+	}
+	if err := match.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// the ratings are all or nothing, so if they fail nothing has been saved
+	// and the client can retry without the win counting twice
+	if err := p.ratings.RecordMatch(match.Winner, match.Losers); err != nil {
+		log.Printf("problem recording match, %v", err)
+		writeError(w, http.StatusInternalServerError, "could not save the ratings")
+		return
+	}
+	if err := p.store.RecordGameWin(match.Winner, match.GameID); err != nil {
+		log.Printf("problem recording match, %v", err)
+		writeError(w, http.StatusInternalServerError, "could not save the win")
+		return
+	}
+
+	ratings := []PlayerRating{}
+	for _, name := range append([]string{match.Winner}, match.Losers...) {
+		rating, _ := p.ratings.GetRating(name)
+		ratings = append(ratings, PlayerRating{name, rating})
+	}
+
+	w.Header().Set("content-type", jsonContentType)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(ratings)
+}
+
+// parseTimeParam reads the query parameter key as an RFC 3339 timestamp or a
+// plain date, which is taken as midnight UTC. A missing parameter is the zero
+// time.
+func parseTimeParam(r *http.Request, key string) (time.Time, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%s must be a date (2006-01-02) or an RFC 3339 time, got %q", key, value)
+}
+
+func validatePlayerName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("player name must not be empty")
+	}
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("player name %q must not contain '/'", name)
+	}
+	return nil
+}
+
+func (p *PlayerServer) playGame(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", htmlContentType)
+	w.Write(gameHTML)
+}
+
+// StopGames closes the /ws games still waiting on a browser and turns new
+// ones away. http.Server.Shutdown doesn't wait for websockets, so register
+// it with RegisterOnShutdown and then call WaitForGames.
+func (p *PlayerServer) StopGames() {
+	p.games.stop()
+}
+
+// WaitForGames blocks until every /ws game has returned, including any that
+// are still saving their winner.
+func (p *PlayerServer) WaitForGames() {
+	p.games.wait()
+}
+
+// webSocket runs one game per connection. The browser first sends the number
+// of players, receives blind alerts while the game runs and finally sends the
+// winner's name.
+func (p *PlayerServer) webSocket(w http.ResponseWriter, r *http.Request) {
+	if !p.games.start() {
+		writeError(w, http.StatusServiceUnavailable, "server is shutting down")
+		return
+	}
+	defer p.games.done()
+
+	ws, err := newPlayerServerWS(w, r)
+	if err != nil {
+		// the upgrader has already replied with an error
+		return
+	}
+	defer ws.Close()
+	defer p.games.closeOnStop(ws)()
+
+	numberOfPlayersMsg, ok := ws.WaitForMsg()
+	if !ok {
+		return
+	}
+	numberOfPlayers, err := strconv.Atoi(strings.TrimSpace(numberOfPlayersMsg))
+	if err != nil || !validNumberOfPlayers(numberOfPlayers) {
+		fmt.Fprint(ws, BadPlayerInputErrMsg)
+		return
+	}
+
+	// stop the blind alerts however the game ends, so they don't outlive
+	// the connection
+	stop := p.game.Start(numberOfPlayers, ws)
+	defer stop()
+
+	for {
+		winner, ok := ws.WaitForMsg()
+		if !ok {
+			return
+		}
+		// the same names /players/{name} can read back
+		winner = strings.TrimSpace(winner)
+		if err := validatePlayerName(winner); err != nil {
+			fmt.Fprint(ws, err.Error())
+			continue
+		}
+
+		if err := p.game.Finish(winner); err != nil {
+			log.Printf("problem finishing game, %v", err)
+			ws.closeWithError("could not save the win")
+		}
+		return
+	}
+}
@@ -1,26 +1,143 @@
 // server_integration_test.go
-package main
+package poker
 
 import (
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestRecordingWinsAndRetrievingThem(t *testing.T) {
 	store := NewInMemoryPlayerStore()
-	server := &PlayerServer{store}
+	server := NewPlayerServer(store, dummyGame, dummyRatings)
 	player := "Pepper"
 
 	server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest(player))
 	server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest(player))
 	server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest(player))
 
-	response := httptest.NewRecorder()
-	server.ServeHTTP(response, newGetScoreRequest(player))
-	assertStatus(t, response.Code, http.StatusOK)
+	server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest("Cleo"))
 
-	assertResponseBody(t, response.Body.String(), "3")
+	t.Run("get score", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newGetScoreRequest(player))
+		assertStatus(t, response.Code, http.StatusOK)
+
+		assertResponseBody(t, response.Body.String(), "3")
+	})
+
+	t.Run("get league", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newLeagueRequest())
+		assertStatus(t, response.Code, http.StatusOK)
+
+		got := getLeagueFromResponse(t, response.Body)
+		want := League{
+			{"Pepper", 3},
+			{"Cleo", 1},
+		}
+		assertLeague(t, got, want)
+	})
+}
+
+func TestRecordingWinsAndRetrievingThemFromFile(t *testing.T) {
+	database := createTempFile(t, "")
+	store, err := NewFileSystemPlayerStore(database)
+	assertNoError(t, err)
+	server := NewPlayerServer(store, dummyGame, dummyRatings)
+	player := "Pepper"
+
+	server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest(player))
+	server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest(player))
+	server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest(player))
+
+	server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest("Cleo"))
+
+	t.Run("get score", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newGetScoreRequest(player))
+		assertStatus(t, response.Code, http.StatusOK)
+
+		assertResponseBody(t, response.Body.String(), "3")
+	})
+
+	t.Run("get league", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newLeagueRequest())
+		assertStatus(t, response.Code, http.StatusOK)
+
+		got := getLeagueFromResponse(t, response.Body)
+		want := League{
+			{"Pepper", 3},
+			{"Cleo", 1},
+		}
+		assertLeague(t, got, want)
+	})
+}
+
+func TestRecordingWinsConcurrently(t *testing.T) {
+	const wantedCount = 2000
+	players := []string{"Pepper", "Cleo", "Chris", "Tiest"}
+
+	store := NewInMemoryPlayerStore()
+	server := NewPlayerServer(store, dummyGame, dummyRatings)
+
+	var wg sync.WaitGroup
+	wg.Add(wantedCount * len(players))
+	for i := 0; i < wantedCount; i++ {
+		for _, player := range players {
+			go func(name string) {
+				defer wg.Done()
+				server.ServeHTTP(httptest.NewRecorder(), newPostWinRequest(name))
+			}(player)
+		}
+	}
+	wg.Wait()
+
+	total := 0
+	for _, player := range players {
+		score := store.GetPlayerScore(player)
+		if score != wantedCount {
+			t.Errorf("got %d wins for %s, want %d", score, player, wantedCount)
+		}
+		total += score
+	}
+	if total != wantedCount*len(players) {
+		t.Errorf("got %d wins in total, want %d", total, wantedCount*len(players))
+	}
+}
+
+func TestPlayingAGameOverWebSocket(t *testing.T) {
+	store := NewInMemoryPlayerStore()
+	// fire only the opening blind, straight away
+	alerter := BlindAlerterFunc(func(duration time.Duration, amount int, to io.Writer) func() {
+		if duration == 0 {
+			fmt.Fprintf(to, "Blind is now %d\n", amount)
+		}
+		return func() {}
+	})
+	game := NewTexasHoldem(alerter, store)
+	server := httptest.NewServer(NewPlayerServer(store, game, dummyRatings))
+	defer server.Close()
+
+	ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+	defer ws.Close()
+
+	writeWSMessage(t, ws, "4")
+	assertWebsocketGotMsg(t, ws, "Blind is now 100\n")
+	writeWSMessage(t, ws, "Pepper")
+
+	passed := retryUntil(500*time.Millisecond, func() bool {
+		return store.GetPlayerScore("Pepper") == 1
+	})
+	if !passed {
+		t.Errorf("got %d wins for Pepper, want 1", store.GetPlayerScore("Pepper"))
+	}
 }
 
 func newPostWinRequest(name string) *http.Request {
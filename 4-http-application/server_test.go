@@ -1,23 +1,76 @@
 // server_test.go
-package main
+package poker
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 type StubPlayerStore struct {
-	scores   map[string]int
-	winCalls []string
+	scores      map[string]int
+	winCalls    []string
+	gameIDCalls []string
+	league      League
+	history     map[string]History
+	imported    League
+	replaced    bool
+	importErr   error
+	winErr      error
 }
 
 func (s *StubPlayerStore) GetPlayerScore(name string) int {
 	return s.scores[name]
 }
 
-func (s *StubPlayerStore) RecordWin(name string) {
-	//
+func (s *StubPlayerStore) LookupPlayerScore(name string) (int, bool) {
+	score, ok := s.scores[name]
+	return score, ok
+}
+
+func (s *StubPlayerStore) RecordWin(name string) error {
+	return s.RecordGameWin(name, "")
+}
+
+func (s *StubPlayerStore) RecordGameWin(name, gameID string) error {
+	if s.winErr != nil {
+		return s.winErr
+	}
+	s.winCalls = append(s.winCalls, name)
+	s.gameIDCalls = append(s.gameIDCalls, gameID)
+	return nil
+}
+
+func (s *StubPlayerStore) GetPlayerHistory(name string, from, to time.Time) History {
+	h := s.history[name]
+	filtered := History{Wins: winsBetween(h.Wins, from, to)}
+	if from.IsZero() {
+		filtered.Undated = h.Undated
+	}
+	return filtered
+}
+
+func (s *StubPlayerStore) GetLeague() League {
+	return s.league
+}
+
+func (s *StubPlayerStore) ImportLeague(league League, replace bool) error {
+	if s.importErr != nil {
+		return s.importErr
+	}
+	s.imported = league
+	s.replaced = replace
+	return nil
 }
 
 func TestGETPlayers(t *testing.T) {
@@ -25,9 +78,10 @@ func TestGETPlayers(t *testing.T) {
 		scores: map[string]int{
 			"Pepper": 20,
 			"Floyd":  10,
+			"Apollo": 0,
 		},
 	}
-	server := &PlayerServer{&store}
+	server := NewPlayerServer(&store, dummyGame, dummyRatings)
 
 	t.Run("returns Pepper's score", func(t *testing.T) {
 		request := newGetScoreRequest("Pepper")
@@ -46,6 +100,909 @@ func TestGETPlayers(t *testing.T) {
 
 		assertResponseBody(t, response.Body.String(), "10")
 	})
+
+	t.Run("returns 0 for a known player without wins", func(t *testing.T) {
+		response := newScoreResponse(server, "Apollo")
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertResponseBody(t, response.Body.String(), "0")
+	})
+
+	t.Run("returns 404 with a JSON error on missing players", func(t *testing.T) {
+		response := newScoreResponse(server, "Lloyd")
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+		assertContentType(t, response, jsonContentType)
+		assertErrorResponse(t, response, http.StatusNotFound)
+	})
+}
+
+func TestStoreWins(t *testing.T) {
+	store := StubPlayerStore{
+		scores: map[string]int{},
+	}
+	server := NewPlayerServer(&store, dummyGame, dummyRatings)
+
+	t.Run("it records wins on POST", func(t *testing.T) {
+		player := "Pepper"
+
+		request := newPostWinRequest(player)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusAccepted)
+		assertPlayerWin(t, &store, player)
+	})
+
+	t.Run("it records the game the win came from", func(t *testing.T) {
+		store := StubPlayerStore{}
+		server := NewPlayerServer(&store, dummyGame, dummyRatings)
+
+		request, _ := http.NewRequest(http.MethodPost, "/players/Pepper?game=friday-1", nil)
+		server.ServeHTTP(httptest.NewRecorder(), request)
+
+		if !reflect.DeepEqual(store.gameIDCalls, []string{"friday-1"}) {
+			t.Errorf("got game IDs %v want %v", store.gameIDCalls, []string{"friday-1"})
+		}
+	})
+
+	t.Run("returns 500 when the win can't be saved", func(t *testing.T) {
+		store := StubPlayerStore{winErr: errors.New("disk full")}
+		server := NewPlayerServer(&store, dummyGame, dummyRatings)
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, newPostWinRequest("Pepper"))
+
+		assertStatus(t, response.Code, http.StatusInternalServerError)
+		assertErrorResponse(t, response, http.StatusInternalServerError)
+	})
+}
+
+func TestHistory(t *testing.T) {
+	friday := time.Date(2026, time.October, 9, 21, 0, 0, 0, time.UTC)
+	saturday := friday.Add(24 * time.Hour)
+
+	store := StubPlayerStore{
+		scores: map[string]int{"Pepper": 3, "Cleo": 0},
+		history: map[string]History{
+			"Pepper": {Undated: 1, Wins: []Win{
+				{At: friday, GameID: "g1"},
+				{At: saturday, GameID: "g2"},
+			}},
+		},
+	}
+	server := NewPlayerServer(&store, dummyGame, dummyRatings)
+
+	t.Run("returns every win as JSON", func(t *testing.T) {
+		response := newHistoryResponse(server, "Pepper", "")
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertContentType(t, response, jsonContentType)
+		got := getHistoryFromResponse(t, response.Body)
+		assertUndated(t, got, 1)
+		assertHistory(t, got.Wins, store.history["Pepper"].Wins)
+	})
+
+	t.Run("filters by from and to", func(t *testing.T) {
+		response := newHistoryResponse(server, "Pepper", "from=2026-10-09&to=2026-10-10")
+
+		assertStatus(t, response.Code, http.StatusOK)
+		got := getHistoryFromResponse(t, response.Body)
+		assertUndated(t, got, 0)
+		assertHistory(t, got.Wins, []Win{{At: friday, GameID: "g1"}})
+	})
+
+	t.Run("accepts RFC 3339 times", func(t *testing.T) {
+		response := newHistoryResponse(server, "Pepper", "from=2026-10-10T00:00:00Z")
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertHistory(t, getHistoryFromResponse(t, response.Body).Wins, []Win{{At: saturday, GameID: "g2"}})
+	})
+
+	t.Run("known player without wins has an empty history", func(t *testing.T) {
+		response := newHistoryResponse(server, "Cleo", "")
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertResponseBody(t, response.Body.String(), `{"Undated":0,"Wins":[]}`+"\n")
+	})
+
+	t.Run("returns 404 for unknown players", func(t *testing.T) {
+		response := newHistoryResponse(server, "Lloyd", "")
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+		assertErrorResponse(t, response, http.StatusNotFound)
+	})
+
+	t.Run("returns 400 for a bad date", func(t *testing.T) {
+		response := newHistoryResponse(server, "Pepper", "from=last-friday")
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+		assertErrorResponse(t, response, http.StatusBadRequest)
+	})
+
+	t.Run("only allows GET", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/players/Pepper/history", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusMethodNotAllowed)
+		if got := response.Header().Get("Allow"); got != http.MethodGet {
+			t.Errorf("got Allow header %q want %q", got, http.MethodGet)
+		}
+	})
+
+	t.Run("a player called history still has a score", func(t *testing.T) {
+		store := StubPlayerStore{scores: map[string]int{"history": 4}}
+		server := NewPlayerServer(&store, dummyGame, dummyRatings)
+
+		response := newScoreResponse(server, "history")
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertResponseBody(t, response.Body.String(), "4")
+	})
+}
+
+func TestRatings(t *testing.T) {
+
+	t.Run("POST /matches records the win and returns new ratings", func(t *testing.T) {
+		store := StubPlayerStore{}
+		server := NewPlayerServer(&store, dummyGame, NewEloRatings(32))
+
+		response := postMatch(server, `{"Winner": "Pepper", "Losers": ["Cleo"], "GameID": "g1"}`)
+
+		assertStatus(t, response.Code, http.StatusAccepted)
+		assertContentType(t, response, jsonContentType)
+		assertPlayerWin(t, &store, "Pepper")
+
+		var got []PlayerRating
+		if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+			t.Fatalf("could not decode ratings %v", err)
+		}
+		if len(got) != 2 || got[0].Name != "Pepper" || got[1].Name != "Cleo" {
+			t.Fatalf("got ratings %v want Pepper then Cleo", got)
+		}
+		assertRating(t, got[0].Rating, 1516)
+		assertRating(t, got[1].Rating, 1484)
+	})
+
+	t.Run("POST /matches returns 500 when the win can't be saved", func(t *testing.T) {
+		store := StubPlayerStore{winErr: errors.New("disk full")}
+		server := NewPlayerServer(&store, dummyGame, NewEloRatings(32))
+
+		response := postMatch(server, `{"Winner": "Pepper", "Losers": ["Cleo"]}`)
+
+		assertStatus(t, response.Code, http.StatusInternalServerError)
+		assertErrorResponse(t, response, http.StatusInternalServerError)
+	})
+
+	t.Run("POST /matches leaves the win alone when the ratings can't be saved", func(t *testing.T) {
+		ratings, err := EloRatingsFromFile(filepath.Join(t.TempDir(), "missing", "ratings.json"), 32)
+		assertNoError(t, err)
+		store := StubPlayerStore{}
+		server := NewPlayerServer(&store, dummyGame, ratings)
+
+		response := postMatch(server, `{"Winner": "Pepper", "Losers": ["Cleo"]}`)
+
+		assertStatus(t, response.Code, http.StatusInternalServerError)
+		assertNoWins(t, &store)
+	})
+
+	badMatches := map[string]string{
+		"not json":            `Pepper beat Cleo`,
+		"no winner":           `{"Losers": ["Cleo"]}`,
+		"no losers":           `{"Winner": "Pepper"}`,
+		"winner also lost":    `{"Winner": "Pepper", "Losers": ["Pepper"]}`,
+		"loser twice":         `{"Winner": "Pepper", "Losers": ["Cleo", "Cleo"]}`,
+		"loser with a slash":  `{"Winner": "Pepper", "Losers": ["Cl/eo"]}`,
+		"winner with a slash": `{"Winner": "Pep/per", "Losers": ["Cleo"]}`,
+	}
+
+	for name, body := range badMatches {
+		t.Run("POST /matches rejects "+name, func(t *testing.T) {
+			store := StubPlayerStore{}
+			server := NewPlayerServer(&store, dummyGame, NewEloRatings(32))
+
+			response := postMatch(server, body)
+
+			assertStatus(t, response.Code, http.StatusBadRequest)
+			assertErrorResponse(t, response, http.StatusBadRequest)
+			if len(store.winCalls) != 0 {
+				t.Errorf("recorded wins %v for a bad match", store.winCalls)
+			}
+		})
+	}
+
+	t.Run("GET /players/{name}/rating", func(t *testing.T) {
+		store := StubPlayerStore{scores: map[string]int{"Pepper": 1, "Chris": 3}}
+		server := NewPlayerServer(&store, dummyGame, NewEloRatings(32))
+		postMatch(server, `{"Winner": "Pepper", "Losers": ["Cleo"]}`)
+
+		cases := map[string]float64{
+			"Pepper": 1516,
+			"Cleo":   1484,
+			"Chris":  InitialRating,
+		}
+		for name, want := range cases {
+			response := newRatingResponse(server, name)
+
+			assertStatus(t, response.Code, http.StatusOK)
+			var got PlayerRating
+			if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+				t.Fatalf("could not decode rating %v", err)
+			}
+			if got.Name != name {
+				t.Errorf("got rating for %q want %q", got.Name, name)
+			}
+			assertRating(t, got.Rating, want)
+		}
+	})
+
+	t.Run("GET /players/{name}/rating returns 404 for unknown players", func(t *testing.T) {
+		server := NewPlayerServer(&StubPlayerStore{}, dummyGame, NewEloRatings(32))
+
+		response := newRatingResponse(server, "Lloyd")
+
+		assertStatus(t, response.Code, http.StatusNotFound)
+		assertErrorResponse(t, response, http.StatusNotFound)
+	})
+
+	t.Run("GET /league?sort=rating orders by rating", func(t *testing.T) {
+		store := StubPlayerStore{league: League{{"Chris", 5}, {"Pepper", 1}}}
+		ratings := NewEloRatings(32)
+		ratings.RecordMatch("Pepper", []string{"Chris"})
+		server := NewPlayerServer(&store, dummyGame, ratings)
+
+		request, _ := http.NewRequest(http.MethodGet, "/league?sort=rating", nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		var got []RatedPlayer
+		if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+			t.Fatalf("could not decode league %v", err)
+		}
+		if len(got) != 2 || got[0].Name != "Pepper" || got[1].Name != "Chris" {
+			t.Errorf("got league %v want Pepper above Chris", got)
+		}
+	})
+
+	t.Run("GET /league rejects an unknown sort", func(t *testing.T) {
+		server := NewPlayerServer(&StubPlayerStore{}, dummyGame, NewEloRatings(32))
+
+		request, _ := http.NewRequest(http.MethodGet, "/league?sort=height", nil)
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+	})
+}
+
+func postMatch(server http.Handler, body string) *httptest.ResponseRecorder {
+	request, _ := http.NewRequest(http.MethodPost, "/matches", strings.NewReader(body))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
+
+func newRatingResponse(server http.Handler, name string) *httptest.ResponseRecorder {
+	request, _ := http.NewRequest(http.MethodGet, "/players/"+name+"/rating", nil)
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
+
+func TestInvalidRequests(t *testing.T) {
+	server := NewPlayerServer(&StubPlayerStore{}, dummyGame, dummyRatings)
+
+	methodCases := []struct {
+		method string
+		path   string
+		allow  string
+	}{
+		{http.MethodPut, "/players/Pepper", "GET, POST"},
+		{http.MethodDelete, "/players/Pepper", "GET, POST"},
+		{http.MethodPost, "/league", "GET"},
+		{http.MethodDelete, "/game", "GET"},
+		{http.MethodGet, "/matches", "POST"},
+		{http.MethodPost, "/players/Pepper/rating", "GET"},
+	}
+
+	for _, c := range methodCases {
+		t.Run(c.method+" "+c.path+" is not allowed", func(t *testing.T) {
+			request, _ := http.NewRequest(c.method, c.path, nil)
+			response := httptest.NewRecorder()
+
+			server.ServeHTTP(response, request)
+
+			assertStatus(t, response.Code, http.StatusMethodNotAllowed)
+			if got := response.Header().Get("Allow"); got != c.allow {
+				t.Errorf("got Allow header %q want %q", got, c.allow)
+			}
+			assertErrorResponse(t, response, http.StatusMethodNotAllowed)
+		})
+	}
+
+	nameCases := map[string]string{
+		"empty name":        "/players/",
+		"blank name":        "/players/%20%20",
+		"name with a slash": "/players/Pepper/Cleo",
+		"escaped slash":     "/players/Pepper%2FCleo",
+	}
+
+	for name, path := range nameCases {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			t.Run(method+" with "+name+" is a bad request", func(t *testing.T) {
+				request, _ := http.NewRequest(method, path, nil)
+				response := httptest.NewRecorder()
+
+				server.ServeHTTP(response, request)
+
+				assertStatus(t, response.Code, http.StatusBadRequest)
+				assertErrorResponse(t, response, http.StatusBadRequest)
+			})
+		}
+	}
+}
+
+func assertErrorResponse(t testing.TB, response *httptest.ResponseRecorder, wantStatus int) {
+	t.Helper()
+
+	var got ErrorResponse
+	if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+		t.Fatalf("could not decode error response %v", err)
+	}
+	if got.Status != wantStatus {
+		t.Errorf("got status %d in error body want %d", got.Status, wantStatus)
+	}
+	if got.Error == "" {
+		t.Error("error body has no message")
+	}
+}
+
+func TestLeague(t *testing.T) {
+
+	t.Run("it returns the league table as JSON", func(t *testing.T) {
+		wantedLeague := League{
+			{"Cleo", 32},
+			{"Chris", 20},
+			{"Tiest", 14},
+		}
+
+		store := StubPlayerStore{league: wantedLeague}
+		server := NewPlayerServer(&store, dummyGame, dummyRatings)
+
+		request := newLeagueRequest()
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		got := getLeagueFromResponse(t, response.Body)
+		assertStatus(t, response.Code, http.StatusOK)
+		assertLeague(t, got, wantedLeague)
+		assertContentType(t, response, jsonContentType)
+	})
+}
+
+func TestLeagueExport(t *testing.T) {
+	league := League{
+		{"Cleo", 32},
+		{"Chris, Jr.", 20},
+	}
+	store := StubPlayerStore{league: league}
+	server := NewPlayerServer(&store, dummyGame, dummyRatings)
+
+	t.Run("exports CSV when asked for it", func(t *testing.T) {
+		response := newExportResponse(server, "text/csv")
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertContentType(t, response, csvContentType)
+		assertResponseBody(t, response.Body.String(), "name,wins\nCleo,32\n\"Chris, Jr.\",20\n")
+	})
+
+	t.Run("exports NDJSON when asked for it", func(t *testing.T) {
+		response := newExportResponse(server, "application/x-ndjson")
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertContentType(t, response, ndjsonContentType)
+		assertResponseBody(t, response.Body.String(), `{"Name":"Cleo","Wins":32}`+"\n"+`{"Name":"Chris, Jr.","Wins":20}`+"\n")
+	})
+
+	t.Run("exports NDJSON by default", func(t *testing.T) {
+		response := newExportResponse(server, "")
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertContentType(t, response, ndjsonContentType)
+	})
+
+	t.Run("goes by the quality the client gives each format", func(t *testing.T) {
+		response := newExportResponse(server, "application/x-ndjson;q=0.5, text/*;q=0.9")
+
+		assertContentType(t, response, csvContentType)
+	})
+
+	t.Run("returns 406 for formats it can't write", func(t *testing.T) {
+		response := newExportResponse(server, "application/xml")
+
+		assertStatus(t, response.Code, http.StatusNotAcceptable)
+		assertErrorResponse(t, response, http.StatusNotAcceptable)
+	})
+
+	t.Run("exports round trip through import", func(t *testing.T) {
+		for _, accept := range []string{"text/csv", "application/x-ndjson"} {
+			exported := newExportResponse(server, accept)
+
+			importer := &StubPlayerStore{}
+			response := postImport(NewPlayerServer(importer, dummyGame, dummyRatings), "", accept, exported.Body.String())
+
+			assertStatus(t, response.Code, http.StatusOK)
+			assertLeague(t, importer.imported, league)
+		}
+	})
+}
+
+func TestLeagueImport(t *testing.T) {
+
+	t.Run("merges a CSV league by default", func(t *testing.T) {
+		store := StubPlayerStore{}
+		server := NewPlayerServer(&store, dummyGame, dummyRatings)
+
+		response := postImport(server, "", "text/csv", "name,wins\nCleo,32\nChris,20\n")
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertContentType(t, response, jsonContentType)
+		assertLeague(t, store.imported, League{{"Cleo", 32}, {"Chris", 20}})
+		if store.replaced {
+			t.Error("replaced the league when merge was asked for")
+		}
+
+		assertResponseBody(t, response.Body.String(), `{"mode":"merge","players":2}`+"\n")
+	})
+
+	t.Run("replaces the league with mode=replace", func(t *testing.T) {
+		store := StubPlayerStore{}
+		server := NewPlayerServer(&store, dummyGame, dummyRatings)
+
+		response := postImport(server, "replace", "application/x-ndjson", `{"Name":"Cleo","Wins":32}`)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertLeague(t, store.imported, League{{"Cleo", 32}})
+		if !store.replaced {
+			t.Error("merged the league when replace was asked for")
+		}
+	})
+
+	t.Run("reports every bad line and imports nothing", func(t *testing.T) {
+		store := StubPlayerStore{}
+		server := NewPlayerServer(&store, dummyGame, dummyRatings)
+
+		response := postImport(server, "", "text/csv", "name,wins\nCleo,32\nChris,lots\n,3\nCleo,1\n")
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+		if store.imported != nil {
+			t.Errorf("imported %v from a bad file", store.imported)
+		}
+
+		var got ImportErrorResponse
+		if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+			t.Fatalf("could not decode import errors, %v", err)
+		}
+		if got.Status != http.StatusBadRequest || got.Error == "" {
+			t.Errorf("got error %+v", got.ErrorResponse)
+		}
+		assertErrorLines(t, got.Lines, 3, 4, 5)
+	})
+
+	t.Run("returns 415 for formats it can't read", func(t *testing.T) {
+		server := NewPlayerServer(&StubPlayerStore{}, dummyGame, dummyRatings)
+
+		response := postImport(server, "", "application/json", `[{"Name":"Cleo","Wins":32}]`)
+
+		assertStatus(t, response.Code, http.StatusUnsupportedMediaType)
+		assertErrorResponse(t, response, http.StatusUnsupportedMediaType)
+	})
+
+	t.Run("returns 400 for an unknown mode", func(t *testing.T) {
+		server := NewPlayerServer(&StubPlayerStore{}, dummyGame, dummyRatings)
+
+		response := postImport(server, "append", "text/csv", "name,wins\n")
+
+		assertStatus(t, response.Code, http.StatusBadRequest)
+		assertErrorResponse(t, response, http.StatusBadRequest)
+	})
+
+	t.Run("returns 413 for a league that is too big", func(t *testing.T) {
+		server := NewPlayerServer(&StubPlayerStore{}, dummyGame, dummyRatings)
+
+		var huge strings.Builder
+		huge.WriteString("name,wins\n")
+		for i := 0; huge.Len() <= maxImportSize; i++ {
+			fmt.Fprintf(&huge, "player-%d,%d\n", i, i)
+		}
+		response := postImport(server, "", "text/csv", huge.String())
+
+		assertStatus(t, response.Code, http.StatusRequestEntityTooLarge)
+		assertErrorResponse(t, response, http.StatusRequestEntityTooLarge)
+	})
+
+	t.Run("returns 500 when the store fails", func(t *testing.T) {
+		store := StubPlayerStore{importErr: errors.New("disk full")}
+		server := NewPlayerServer(&store, dummyGame, dummyRatings)
+
+		response := postImport(server, "", "text/csv", "name,wins\nCleo,32\n")
+
+		assertStatus(t, response.Code, http.StatusInternalServerError)
+		assertErrorResponse(t, response, http.StatusInternalServerError)
+	})
+
+	t.Run("only accepts POST", func(t *testing.T) {
+		server := NewPlayerServer(&StubPlayerStore{}, dummyGame, dummyRatings)
+		request, _ := http.NewRequest(http.MethodGet, "/league/import", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusMethodNotAllowed)
+	})
+}
+
+func newExportResponse(server http.Handler, accept string) *httptest.ResponseRecorder {
+	request, _ := http.NewRequest(http.MethodGet, "/league/export", nil)
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
+
+func postImport(server http.Handler, mode, contentType, body string) *httptest.ResponseRecorder {
+	url := "/league/import"
+	if mode != "" {
+		url += "?mode=" + mode
+	}
+	request, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	request.Header.Set("content-type", contentType)
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
+
+func TestGame(t *testing.T) {
+
+	t.Run("GET /game returns 200 and the game page", func(t *testing.T) {
+		server := NewPlayerServer(&StubPlayerStore{}, dummyGame, dummyRatings)
+
+		request := newGameRequest()
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusOK)
+		assertContentType(t, response, htmlContentType)
+		if !strings.Contains(response.Body.String(), "/ws") {
+			t.Errorf("game page does not connect to /ws, got %q", response.Body.String())
+		}
+	})
+
+	t.Run("start a game with 3 players, send some blind alerts down WS and declare Ruth the winner", func(t *testing.T) {
+		wantedBlindAlert := "Blind is 100"
+		winner := "Ruth"
+
+		game := &GameSpy{BlindAlert: []byte(wantedBlindAlert)}
+		server := httptest.NewServer(NewPlayerServer(dummyPlayerStore, game, dummyRatings))
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+
+		writeWSMessage(t, ws, "3")
+		assertWebsocketGotMsg(t, ws, wantedBlindAlert)
+
+		writeWSMessage(t, ws, winner)
+
+		assertGameStartedWith(t, game, 3)
+		assertFinishCalledWith(t, game, winner)
+		assertGameStopped(t, game)
+	})
+
+	t.Run("it closes the socket with an internal error when the win can't be saved", func(t *testing.T) {
+		game := &GameSpy{FinishErr: errors.New("disk full")}
+		server := httptest.NewServer(NewPlayerServer(dummyPlayerStore, game, dummyRatings))
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+
+		writeWSMessage(t, ws, "3")
+		writeWSMessage(t, ws, "Ruth")
+
+		assertWebsocketClosedWith(t, ws, websocket.CloseInternalServerErr)
+	})
+
+	t.Run("it asks again for a winner whose name can't be stored", func(t *testing.T) {
+		game := &GameSpy{BlindAlert: []byte("Blind is 100")}
+		server := httptest.NewServer(NewPlayerServer(dummyPlayerStore, game, dummyRatings))
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+
+		writeWSMessage(t, ws, "3")
+		assertWebsocketGotMsg(t, ws, "Blind is 100")
+		writeWSMessage(t, ws, "Ru/th")
+		assertWebsocketGotMsg(t, ws, validatePlayerName("Ru/th").Error())
+		assertGameNotFinished(t, game)
+
+		writeWSMessage(t, ws, "Ruth")
+		assertFinishCalledWith(t, game, "Ruth")
+	})
+
+	t.Run("it stops the game when the socket closes without a winner", func(t *testing.T) {
+		game := &GameSpy{}
+		server := httptest.NewServer(NewPlayerServer(dummyPlayerStore, game, dummyRatings))
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		writeWSMessage(t, ws, "3")
+		assertGameStartedWith(t, game, 3)
+		ws.Close()
+
+		assertGameStopped(t, game)
+		assertGameNotFinished(t, game)
+	})
+
+	t.Run("it rejects more than MaxPlayers players", func(t *testing.T) {
+		game := &GameSpy{}
+		server := httptest.NewServer(NewPlayerServer(dummyPlayerStore, game, dummyRatings))
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+
+		writeWSMessage(t, ws, fmt.Sprint(MaxPlayers+1))
+		assertWebsocketGotMsg(t, ws, BadPlayerInputErrMsg)
+
+		assertGameNotStarted(t, game)
+	})
+
+	t.Run("it rejects a bad number of players and does not start the game", func(t *testing.T) {
+		game := &GameSpy{}
+		server := httptest.NewServer(NewPlayerServer(dummyPlayerStore, game, dummyRatings))
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+
+		writeWSMessage(t, ws, "pies")
+		assertWebsocketGotMsg(t, ws, BadPlayerInputErrMsg)
+
+		assertGameNotStarted(t, game)
+	})
+}
+
+// FinishBlocker is a GameSpy whose Finish waits to be released, like a
+// store that is slow to save the winner.
+type FinishBlocker struct {
+	GameSpy
+	finishing chan string
+	release   chan struct{}
+}
+
+func (g *FinishBlocker) Finish(winner string) error {
+	g.finishing <- winner
+	<-g.release
+	return g.GameSpy.Finish(winner)
+}
+
+func TestGameShutdown(t *testing.T) {
+
+	t.Run("stopping closes games waiting on the browser", func(t *testing.T) {
+		game := &GameSpy{}
+		players := NewPlayerServer(dummyPlayerStore, game, dummyRatings)
+		server := httptest.NewServer(players)
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+		writeWSMessage(t, ws, "3")
+		assertGameStartedWith(t, game, 3)
+
+		players.StopGames()
+		players.WaitForGames()
+
+		assertWebsocketClosedWith(t, ws, websocket.CloseGoingAway)
+		assertGameStopped(t, game)
+		assertGameNotFinished(t, game)
+	})
+
+	t.Run("waiting lets a game finish saving its winner", func(t *testing.T) {
+		game := &FinishBlocker{finishing: make(chan string), release: make(chan struct{})}
+		players := NewPlayerServer(dummyPlayerStore, game, dummyRatings)
+		server := httptest.NewServer(players)
+		defer server.Close()
+
+		ws := mustDialWS(t, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws")
+		defer ws.Close()
+		writeWSMessage(t, ws, "3")
+		writeWSMessage(t, ws, "Ruth")
+		<-game.finishing
+
+		players.StopGames()
+		waited := make(chan struct{})
+		go func() {
+			players.WaitForGames()
+			close(waited)
+		}()
+
+		select {
+		case <-waited:
+			t.Fatal("stopped waiting before the winner was saved")
+		case <-time.After(10 * time.Millisecond):
+		}
+		close(game.release)
+
+		select {
+		case <-waited:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the game")
+		}
+		assertFinishCalledWith(t, &game.GameSpy, "Ruth")
+	})
+
+	t.Run("turns new games away once stopped", func(t *testing.T) {
+		players := NewPlayerServer(dummyPlayerStore, &GameSpy{}, dummyRatings)
+		players.StopGames()
+
+		request, _ := http.NewRequest(http.MethodGet, "/ws", nil)
+		response := httptest.NewRecorder()
+		players.ServeHTTP(response, request)
+
+		assertStatus(t, response.Code, http.StatusServiceUnavailable)
+		assertErrorResponse(t, response, http.StatusServiceUnavailable)
+	})
+}
+
+func newHistoryResponse(server http.Handler, name, query string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodGet, "/players/"+name+"/history?"+query, nil)
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, req)
+	return response
+}
+
+func getHistoryFromResponse(t testing.TB, body io.Reader) (history History) {
+	t.Helper()
+	err := json.NewDecoder(body).Decode(&history)
+	if err != nil {
+		t.Fatalf("Unable to parse response from server %q into History, '%v'", body, err)
+	}
+	return
+}
+
+func assertUndated(t testing.TB, got History, want int) {
+	t.Helper()
+	if got.Undated != want {
+		t.Errorf("got %d undated wins want %d", got.Undated, want)
+	}
+}
+
+func assertHistory(t testing.TB, got, want []Win) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d wins %v want %d %v", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if !got[i].At.Equal(want[i].At) || got[i].GameID != want[i].GameID {
+			t.Errorf("win %d: got %v want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func newGameRequest() *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "/game", nil)
+	return req
+}
+
+func mustDialWS(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("could not open a ws connection on %s %v", url, err)
+	}
+	return ws
+}
+
+func writeWSMessage(t testing.TB, conn *websocket.Conn, message string) {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+		t.Fatalf("could not send message over ws connection %v", err)
+	}
+}
+
+func assertWebsocketGotMsg(t *testing.T, ws *websocket.Conn, want string) {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	_, msg, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatalf("could not read from ws connection %v", err)
+	}
+	if string(msg) != want {
+		t.Errorf("got %q, want %q", string(msg), want)
+	}
+}
+
+// assertWebsocketClosedWith reads past any messages still to come and checks
+// the server closed the socket with code.
+func assertWebsocketClosedWith(t *testing.T, ws *websocket.Conn, code int) {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	var err error
+	for err == nil {
+		_, _, err = ws.ReadMessage()
+	}
+	if !websocket.IsCloseError(err, code) {
+		t.Errorf("got %v want the socket closed with %d", err, code)
+	}
+}
+
+// retryUntil polls cond until it holds or d has passed, for things that happen
+// on the server's goroutine after the client has moved on.
+func retryUntil(d time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return cond()
+}
+
+func newLeagueRequest() *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "/league", nil)
+	return req
+}
+
+func getLeagueFromResponse(t testing.TB, body io.Reader) (league League) {
+	t.Helper()
+	err := json.NewDecoder(body).Decode(&league)
+	if err != nil {
+		t.Fatalf("Unable to parse response from server %q into League, '%v'", body, err)
+	}
+	return
+}
+
+func assertLeague(t testing.TB, got, want League) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func assertContentType(t testing.TB, response *httptest.ResponseRecorder, want string) {
+	t.Helper()
+	if response.Result().Header.Get("content-type") != want {
+		t.Errorf("response did not have content-type of %s, got %v", want, response.Result().Header)
+	}
+}
+
+func newScoreResponse(server http.Handler, name string) *httptest.ResponseRecorder {
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, newGetScoreRequest(name))
+	return response
+}
+
+func assertPlayerWin(t testing.TB, store *StubPlayerStore, winner string) {
+	t.Helper()
+
+	if len(store.winCalls) != 1 {
+		t.Fatalf("got %d calls to RecordWin want %d", len(store.winCalls), 1)
+	}
+
+	if store.winCalls[0] != winner {
+		t.Errorf("did not store correct winner got %q want %q", store.winCalls[0], winner)
+	}
 }
 
 func newGetScoreRequest(name string) *http.Request {
@@ -0,0 +1,278 @@
+// sql_player_store.go
+package poker
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+// SQLPlayerStore keeps the league in a relational database. Every win is a
+// row in the wins table and players.wins counts them, updated in the same
+// transaction. Wins counted before the wins table existed have no row and
+// show up in the history undated. The statements use SQLite's dialect.
+type SQLPlayerStore struct {
+	db    *sql.DB
+	clock Clock
+}
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS players (
+		name TEXT PRIMARY KEY,
+		wins INTEGER NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE IF NOT EXISTS wins (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL REFERENCES players (name),
+		at INTEGER NOT NULL,
+		game_id TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX IF NOT EXISTS wins_by_player ON wins (name, at)`,
+}
+
+// NewSQLPlayerStore creates the tables it needs if they don't exist yet.
+func NewSQLPlayerStore(db *sql.DB, opts ...StoreOption) (*SQLPlayerStore, error) {
+	for _, statement := range schema {
+		if _, err := db.Exec(statement); err != nil {
+			return nil, fmt.Errorf("problem creating schema, %v", err)
+		}
+	}
+	return &SQLPlayerStore{db: db, clock: newStoreOptions(opts).clock}, nil
+}
+
+func (s *SQLPlayerStore) GetPlayerScore(name string) int {
+	score, _ := s.LookupPlayerScore(name)
+	return score
+}
+
+func (s *SQLPlayerStore) LookupPlayerScore(name string) (int, bool) {
+	var wins int
+	err := s.db.QueryRow(`SELECT wins FROM players WHERE name = ?`, name).Scan(&wins)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("problem looking up score for %s, %v", name, err)
+		}
+		return 0, false
+	}
+	return wins, true
+}
+
+func (s *SQLPlayerStore) RecordWin(name string) error {
+	return s.RecordGameWin(name, "")
+}
+
+// RecordGameWin inserts the player with one win or bumps an existing row and
+// logs the win, all in one transaction so the two can't drift apart.
+func (s *SQLPlayerStore) RecordGameWin(name, gameID string) error {
+	if err := s.recordGameWin(name, gameID); err != nil {
+		return fmt.Errorf("problem recording win for %s, %v", name, err)
+	}
+	return nil
+}
+
+func (s *SQLPlayerStore) recordGameWin(name, gameID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO players (name, wins) VALUES (?, 1)
+		ON CONFLICT (name) DO UPDATE SET wins = wins + 1`, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO wins (name, at, game_id) VALUES (?, ?, ?)`,
+		name, s.clock.now().UnixNano(), gameID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetPlayerHistory reads the player's wins in the order they happened.
+func (s *SQLPlayerStore) GetPlayerHistory(name string, from, to time.Time) History {
+	history, err := s.playerHistory(name, from, to)
+	if err != nil {
+		log.Printf("problem reading history for %s, %v", name, err)
+		return History{Wins: []Win{}}
+	}
+	return history
+}
+
+func (s *SQLPlayerStore) playerHistory(name string, from, to time.Time) (History, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return History{}, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT at, game_id FROM wins WHERE name = ?`
+	args := []any{name}
+	if !from.IsZero() {
+		query += ` AND at >= ?`
+		args = append(args, from.UnixNano())
+	}
+	if !to.IsZero() {
+		query += ` AND at < ?`
+		args = append(args, to.UnixNano())
+	}
+	query += ` ORDER BY at, id`
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return History{}, err
+	}
+	defer rows.Close()
+
+	dated := []Win{}
+	for rows.Next() {
+		var at int64
+		var w Win
+		if err := rows.Scan(&at, &w.GameID); err != nil {
+			return History{}, err
+		}
+		w.At = time.Unix(0, at).UTC()
+		dated = append(dated, w)
+	}
+	if err := rows.Err(); err != nil {
+		return History{}, err
+	}
+
+	// undated wins sit before everything, so only an open start includes them
+	history := History{Wins: dated}
+	if !from.IsZero() {
+		return history, nil
+	}
+	err = tx.QueryRow(`SELECT p.wins - (SELECT COUNT(*) FROM wins w WHERE w.name = p.name)
+		FROM players p WHERE p.name = ?`, name).Scan(&history.Undated)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return History{}, err
+	}
+	history.Undated = max(history.Undated, 0)
+	return history, nil
+}
+
+func (s *SQLPlayerStore) GetLeague() League {
+	league := League{}
+
+	rows, err := s.db.Query(`SELECT name, wins FROM players ORDER BY wins DESC, name ASC`)
+	if err != nil {
+		log.Printf("problem reading league, %v", err)
+		return league
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p Player
+		if err := rows.Scan(&p.Name, &p.Wins); err != nil {
+			log.Printf("problem reading league, %v", err)
+			return league
+		}
+		league = append(league, p)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("problem reading league, %v", err)
+	}
+	return league
+}
+
+// ImportLeague writes every imported score in one transaction. A lower score
+// deletes the player's oldest win rows; a higher one leaves the extra wins
+// undated, the same as wins counted before the wins table existed.
+func (s *SQLPlayerStore) ImportLeague(league League, replace bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if replace {
+		if err := deletePlayersNotIn(tx, league); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range league {
+		_, err := tx.Exec(`INSERT INTO players (name, wins) VALUES (?, ?)
+			ON CONFLICT (name) DO UPDATE SET wins = excluded.wins`, p.Name, p.Wins)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`DELETE FROM wins WHERE name = ? AND id NOT IN (
+			SELECT id FROM wins WHERE name = ? ORDER BY at DESC, id DESC LIMIT ?)`,
+			p.Name, p.Name, p.Wins)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func deletePlayersNotIn(tx *sql.Tx, league League) error {
+	keep := map[string]bool{}
+	for _, p := range league {
+		keep[p.Name] = true
+	}
+
+	rows, err := tx.Query(`SELECT name FROM players`)
+	if err != nil {
+		return err
+	}
+	var gone []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		if !keep[name] {
+			gone = append(gone, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, name := range gone {
+		if _, err := tx.Exec(`DELETE FROM wins WHERE name = ?`, name); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM players WHERE name = ?`, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SQLPlayerStoreFromFile opens (or creates) the SQLite database at path and
+// builds a store on top of it. SQLite allows one writer at a time, so the
+// pool is limited to a single connection. The returned func closes the
+// database.
+func SQLPlayerStoreFromFile(path string, opts ...StoreOption) (*SQLPlayerStore, func(), error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("problem opening %s %v", path, err)
+	}
+	db.SetMaxOpenConns(1)
+
+	store, err := NewSQLPlayerStore(db, opts...)
+	if err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("problem creating sql player store, %v", err)
+	}
+
+	closeFunc := func() {
+		db.Close()
+	}
+	return store, closeFunc, nil
+}
@@ -0,0 +1,48 @@
+// sql_player_store_test.go
+package poker
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLPlayerStore(t *testing.T) {
+
+	t.Run("wins survive reopening the database", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "league.db")
+
+		store, closeDB, err := SQLPlayerStoreFromFile(path)
+		assertNoError(t, err)
+		store.RecordWin("Pepper")
+		store.RecordWin("Pepper")
+		closeDB()
+
+		reopened, closeDB, err := SQLPlayerStoreFromFile(path)
+		assertNoError(t, err)
+		defer closeDB()
+
+		assertScoreEquals(t, reopened.GetPlayerScore("Pepper"), 2)
+	})
+
+	t.Run("serves the league over HTTP", func(t *testing.T) {
+		store := createTempSQLStore(t)
+		server := NewPlayerServer(store, dummyGame, dummyRatings)
+
+		store.RecordWin("Pepper")
+		store.RecordWin("Cleo")
+		store.RecordWin("Pepper")
+
+		response := newScoreResponse(server, "Pepper")
+		assertResponseBody(t, response.Body.String(), "2")
+	})
+}
+
+func createTempSQLStore(t testing.TB) *SQLPlayerStore {
+	t.Helper()
+
+	store, closeDB, err := SQLPlayerStoreFromFile(filepath.Join(t.TempDir(), "league.db"))
+	assertNoError(t, err)
+	t.Cleanup(closeDB)
+
+	return store
+}
@@ -0,0 +1,30 @@
+// tape.go
+package poker
+
+import "io"
+
+type truncater interface {
+	Truncate(size int64) error
+}
+
+// tape rewrites the whole underlying file on every Write: it writes from the
+// start and then cuts the file down to the new length. The file is
+// overwritten in place, so a crash or short write part way through leaves it
+// corrupt.
+type tape struct {
+	file io.ReadWriteSeeker
+}
+
+func (t *tape) Write(p []byte) (n int, err error) {
+	if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err = t.file.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if f, ok := t.file.(truncater); ok {
+		err = f.Truncate(int64(n))
+	}
+	return n, err
+}
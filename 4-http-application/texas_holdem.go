@@ -0,0 +1,49 @@
+// texas_holdem.go
+package poker
+
+import (
+	"io"
+	"time"
+)
+
+var blinds = []int{100, 200, 300, 400, 500, 600, 800, 1000, 2000, 4000, 8000}
+
+// TexasHoldem is a Game that raises the blinds on a schedule and records the
+// winner in a PlayerStore.
+type TexasHoldem struct {
+	alerter BlindAlerter
+	store   PlayerStore
+}
+
+func NewTexasHoldem(alerter BlindAlerter, store PlayerStore) *TexasHoldem {
+	return &TexasHoldem{
+		alerter: alerter,
+		store:   store,
+	}
+}
+
+// Start schedules every blind increase up front, sending each alert to
+// alertsDestination, and returns a func that cancels the ones still to come.
+// The more players there are, the longer each blind level lasts; more than
+// MaxPlayers count as MaxPlayers.
+func (p *TexasHoldem) Start(numberOfPlayers int, alertsDestination io.Writer) func() {
+	numberOfPlayers = min(max(numberOfPlayers, 0), MaxPlayers)
+	blindIncrement := time.Duration(5+numberOfPlayers) * time.Minute
+
+	cancels := make([]func(), 0, len(blinds))
+	blindTime := 0 * time.Second
+	for _, blind := range blinds {
+		cancels = append(cancels, p.alerter.ScheduleAlertAt(blindTime, blind, alertsDestination))
+		blindTime = blindTime + blindIncrement
+	}
+
+	return func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+}
+
+func (p *TexasHoldem) Finish(winner string) error {
+	return p.store.RecordWin(winner)
+}
@@ -0,0 +1,188 @@
+// texas_holdem_test.go
+package poker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+type scheduledAlert struct {
+	at     time.Duration
+	amount int
+	to     io.Writer
+}
+
+func (s scheduledAlert) String() string {
+	return fmt.Sprintf("%d chips at %v", s.amount, s.at)
+}
+
+type SpyBlindAlerter struct {
+	alerts    []scheduledAlert
+	cancelled int
+}
+
+func (s *SpyBlindAlerter) ScheduleAlertAt(at time.Duration, amount int, to io.Writer) func() {
+	s.alerts = append(s.alerts, scheduledAlert{at, amount, to})
+	return func() { s.cancelled++ }
+}
+
+var dummyBlindAlerter = &SpyBlindAlerter{}
+var dummyPlayerStore = &StubPlayerStore{}
+var dummyRatings = NewEloRatings(DefaultKFactor)
+
+func TestGame_Start(t *testing.T) {
+
+	t.Run("schedules alerts on game start for 5 players", func(t *testing.T) {
+		blindAlerter := &SpyBlindAlerter{}
+		game := NewTexasHoldem(blindAlerter, dummyPlayerStore)
+
+		game.Start(5, io.Discard)
+
+		cases := []scheduledAlert{
+			{at: 0 * time.Second, amount: 100},
+			{at: 10 * time.Minute, amount: 200},
+			{at: 20 * time.Minute, amount: 300},
+			{at: 30 * time.Minute, amount: 400},
+			{at: 40 * time.Minute, amount: 500},
+			{at: 50 * time.Minute, amount: 600},
+			{at: 60 * time.Minute, amount: 800},
+			{at: 70 * time.Minute, amount: 1000},
+			{at: 80 * time.Minute, amount: 2000},
+			{at: 90 * time.Minute, amount: 4000},
+			{at: 100 * time.Minute, amount: 8000},
+		}
+
+		checkSchedulingCases(t, cases, blindAlerter)
+	})
+
+	t.Run("schedules alerts on game start for 7 players", func(t *testing.T) {
+		blindAlerter := &SpyBlindAlerter{}
+		game := NewTexasHoldem(blindAlerter, dummyPlayerStore)
+
+		game.Start(7, io.Discard)
+
+		cases := []scheduledAlert{
+			{at: 0 * time.Second, amount: 100},
+			{at: 12 * time.Minute, amount: 200},
+			{at: 24 * time.Minute, amount: 300},
+			{at: 36 * time.Minute, amount: 400},
+		}
+
+		checkSchedulingCases(t, cases, blindAlerter)
+	})
+
+	t.Run("treats more than MaxPlayers as MaxPlayers", func(t *testing.T) {
+		blindAlerter := &SpyBlindAlerter{}
+		game := NewTexasHoldem(blindAlerter, dummyPlayerStore)
+
+		game.Start(1<<62, io.Discard)
+
+		cases := []scheduledAlert{
+			{at: 0 * time.Second, amount: 100},
+			{at: 15 * time.Minute, amount: 200},
+		}
+
+		checkSchedulingCases(t, cases, blindAlerter)
+	})
+
+	t.Run("stopping cancels every alert", func(t *testing.T) {
+		blindAlerter := &SpyBlindAlerter{}
+		game := NewTexasHoldem(blindAlerter, dummyPlayerStore)
+
+		stop := game.Start(5, io.Discard)
+		stop()
+
+		if blindAlerter.cancelled != len(blinds) {
+			t.Errorf("got %d alerts cancelled want %d", blindAlerter.cancelled, len(blinds))
+		}
+	})
+}
+
+func TestAlerter(t *testing.T) {
+	t.Run("writes the blind once the time is up", func(t *testing.T) {
+		out := &safeBuffer{}
+		Alerter(time.Millisecond, 100, out)
+
+		if !retryUntil(time.Second, func() bool { return out.String() == "Blind is now 100\n" }) {
+			t.Errorf("got %q want the blind", out.String())
+		}
+	})
+
+	t.Run("writes nothing once cancelled", func(t *testing.T) {
+		out := &safeBuffer{}
+		cancel := Alerter(10*time.Millisecond, 100, out)
+		cancel()
+
+		time.Sleep(30 * time.Millisecond)
+		if out.String() != "" {
+			t.Errorf("got %q after cancelling want nothing", out.String())
+		}
+	})
+}
+
+// safeBuffer is a bytes.Buffer that a timer can write to while the test
+// reads it.
+type safeBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *safeBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *safeBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
+}
+
+func TestGame_StartSendsAlertsToDestination(t *testing.T) {
+	blindAlerter := &SpyBlindAlerter{}
+	game := NewTexasHoldem(blindAlerter, dummyPlayerStore)
+	destination := &bytes.Buffer{}
+
+	game.Start(3, destination)
+
+	for _, alert := range blindAlerter.alerts {
+		if alert.to != destination {
+			t.Fatalf("alert %v was not scheduled to the game's destination", alert)
+		}
+	}
+}
+
+func TestGame_Finish(t *testing.T) {
+	store := &StubPlayerStore{}
+	game := NewTexasHoldem(dummyBlindAlerter, store)
+	winner := "Ruth"
+
+	assertNoError(t, game.Finish(winner))
+	assertPlayerWin(t, store, winner)
+}
+
+func checkSchedulingCases(t *testing.T, cases []scheduledAlert, blindAlerter *SpyBlindAlerter) {
+	t.Helper()
+	for i, want := range cases {
+		t.Run(fmt.Sprint(want), func(t *testing.T) {
+			if len(blindAlerter.alerts) <= i {
+				t.Fatalf("alert %d was not scheduled %v", i, blindAlerter.alerts)
+			}
+
+			got := blindAlerter.alerts[i]
+			assertScheduledAlert(t, got, want)
+		})
+	}
+}
+
+func assertScheduledAlert(t testing.TB, got, want scheduledAlert) {
+	t.Helper()
+	if got.at != want.at || got.amount != want.amount {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
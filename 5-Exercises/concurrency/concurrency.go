@@ -1,21 +1,13 @@
 package concurrency
 
 import (
-	"sync"
+	"context"
 	"time"
 )
 
-type Sleeper interface {
-	Sleep()
-}
-
-type DefaultSleeper struct{}
-
-func (d *DefaultSleeper) Sleep() {
-	time.Sleep(1 * time.Second)
-}
+const dummyDelay = 1 * time.Second
 
-func newSleeper() Sleeper {
+func newSleeper() ContextSleeper {
 	return &DefaultSleeper{}
 }
 
@@ -23,49 +15,21 @@ func DummyFunction(n int) int {
 	return n * 2
 }
 
-func ConcurrentDummyFunctionWithWaitGroup(numbers []int, sleeper Sleeper) []int {
-	results := make([]int, len(numbers))
-	var wg sync.WaitGroup
-	for i, n := range numbers {
-		wg.Add(1)
-		go func(i, n int) {
-			defer wg.Done()
-			sleeper.Sleep()
-			results[i] = DummyFunction(n)
-		}(i, n)
-	}
-	wg.Wait()
-	return results
+// Deprecated: use ParallelMap. It is the same as ConcurrentDummyFunction.
+func ConcurrentDummyFunctionWithWaitGroup(ctx context.Context, numbers []int, sleeper ContextSleeper) ([]int, error) {
+	return ConcurrentDummyFunction(ctx, numbers, sleeper)
 }
 
-/*
-type keyValue struct {
-	index int
-	value int
-}
-*/
-
-func ConcurrentDummyFunction(numbers []int, sleeper Sleeper) []int {
-	results := make([]int, len(numbers))
-	resultChannel := make(chan struct {
-		index int
-		value int
-	})
-	for i, n := range numbers {
-		go func(i, n int) {
-			result := DummyFunction(n)
-			sleeper.Sleep()
-			resultChannel <- struct {
-				index int
-				value int
-			}{i, result}
-
-			//resultChannel <- keyValue{i, result}
-		}(i, n)
-	}
-	for range numbers {
-		result := <-resultChannel
-		results[result.index] = result.value
-	}
-	return results
+// ConcurrentDummyFunction sleeps then doubles every number at once.
+// Cancelling ctx wakes the sleepers and it returns an error wrapping
+// ctx.Err().
+//
+// Deprecated: use ParallelMap.
+func ConcurrentDummyFunction(ctx context.Context, numbers []int, sleeper ContextSleeper) ([]int, error) {
+	return ParallelMap(ctx, numbers, func(ctx context.Context, n int) (int, error) {
+		if err := sleeper.Sleep(ctx, dummyDelay); err != nil {
+			return 0, err
+		}
+		return DummyFunction(n), nil
+	}, Options{})
 }
@@ -1,17 +1,24 @@
 package concurrency
 
 import (
+	"context"
+	"errors"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
 
 type SleeperMock struct {
+	mu    sync.Mutex
 	Calls int
 }
 
-func (s *SleeperMock) Sleep() {
+func (s *SleeperMock) Sleep(ctx context.Context, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.Calls++
+	return ctx.Err()
 }
 
 func TestDummyFunction(t *testing.T) {
@@ -24,45 +31,52 @@ func TestDummyFunction(t *testing.T) {
 }
 
 func TestConcurrentDummyFunction(t *testing.T) {
-	numbers := []int{1, 2, 3}
-	sleeper := SleeperMock{}
-	got := ConcurrentDummyFunction(numbers, &sleeper)
-	want := []int{2, 4, 6}
+	t.Run("doubles the numbers in order", func(t *testing.T) {
+		sleeper := &SleeperMock{}
+		got, err := ConcurrentDummyFunction(context.Background(), []int{1, 2, 3}, sleeper)
 
-	if !reflect.DeepEqual(got, want) {
-		t.Errorf("got %v want %v", got, want)
-	}
-}
+		assertNoError(t, err)
+		assertResults(t, got, []int{2, 4, 6})
+		if sleeper.Calls != 3 {
+			t.Errorf("expected function to run 3 times, but it ran %d times", sleeper.Calls)
+		}
+	})
 
-func TestParallelExecutionWithWaitGroup(t *testing.T) {
-	numbers := []int{1, 2, 3}
-	start := time.Now()
-	sleeper := SleeperMock{}
-	ConcurrentDummyFunctionWithWaitGroup(numbers, &sleeper)
-	duration := time.Since(start)
+	t.Run("sleeps for every number at once", func(t *testing.T) {
+		sleeper := &FakeSleeper{}
 
-	if duration >= 3*time.Second {
-		t.Errorf("expected function to run in less than 3 seconds, but it took %v", duration)
-	}
-}
+		done := make(chan []int, 1)
+		go func() {
+			got, _ := ConcurrentDummyFunction(context.Background(), []int{1, 2, 3}, sleeper)
+			done <- got
+		}()
+		assertNoError(t, sleeper.WaitForSleepers(3, time.Second))
+		sleeper.Advance(dummyDelay)
 
-func TestParallelExecution(t *testing.T) {
-	numbers := []int{1, 2, 3}
-	start := time.Now()
-	sleeper := SleeperMock{}
-	ConcurrentDummyFunction(numbers, &sleeper)
-	duration := time.Since(start)
+		assertResults(t, <-done, []int{2, 4, 6})
+		want := []time.Duration{dummyDelay, dummyDelay, dummyDelay}
+		if got := sleeper.Durations(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got sleeps %v want %v", got, want)
+		}
+	})
 
-	if duration >= 3*time.Second {
-		t.Errorf("expected function to run in less than 3 seconds, but it took %v", duration)
-	}
-}
+	t.Run("stops when cancelled", func(t *testing.T) {
+		sleeper := &FakeSleeper{}
+		ctx, cancel := context.WithCancel(context.Background())
 
-func TestParallelExecutionWithMock(t *testing.T) {
-	numbers := []int{1, 2, 3}
-	sleeper := SleeperMock{}
-	ConcurrentDummyFunction(numbers, &sleeper)
-	if sleeper.Calls != 3 {
-		t.Errorf("expected function to run 3 times, but it ran %d times", sleeper.Calls)
-	}
+		done := make(chan error, 1)
+		go func() {
+			_, err := ConcurrentDummyFunction(ctx, []int{1, 2, 3}, sleeper)
+			done <- err
+		}()
+		assertNoError(t, sleeper.WaitForSleepers(3, time.Second))
+		cancel()
+
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v want %v", err, context.Canceled)
+		}
+		if sleeper.Sleeping() != 0 {
+			t.Errorf("got %d sleepers still waiting want none", sleeper.Sleeping())
+		}
+	})
 }
@@ -0,0 +1,134 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// ErrorMode decides what ParallelMap does when fn fails.
+type ErrorMode int
+
+const (
+	// FailFast stops at the first error: fn's context is cancelled and no
+	// more items are started.
+	FailFast ErrorMode = iota
+	// CollectAll runs every item and reports all the errors together.
+	CollectAll
+)
+
+// Options tune ParallelMap. The zero value runs every item at once and
+// fails fast.
+type Options struct {
+	// Workers is how many items run at a time; zero or less means all of
+	// them.
+	Workers   int
+	ErrorMode ErrorMode
+}
+
+// ItemError is fn failing for the item at Index.
+type ItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is a panic in fn, caught so the other items carry on.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// ParallelMap calls fn on every item concurrently and returns the results
+// in the same order as items. An item that fails or is never started
+// because of cancellation leaves the zero R in its place.
+//
+// Errors from fn, including recovered panics, come back as *ItemError. With
+// FailFast the error is the first one to happen; with CollectAll it joins
+// them all in item order. If ctx is cancelled, no more items are started
+// and ctx.Err() is part of the error.
+func ParallelMap[T, R any](ctx context.Context, items []T, fn func(context.Context, T) (R, error), opts Options) ([]R, error) {
+	results := make([]R, len(items))
+	itemErrs := make([]error, len(items))
+
+	workers := opts.Workers
+	if workers <= 0 || workers > len(items) {
+		workers = len(items)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	indexes := make(chan int)
+	go func() {
+		defer close(indexes)
+		for i := range items {
+			select {
+			case indexes <- i:
+			case <-runCtx.Done():
+				return
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if runCtx.Err() != nil {
+					continue
+				}
+				result, err := callRecovering(runCtx, fn, items[i])
+				if err == nil {
+					results[i] = result
+					continue
+				}
+
+				itemErrs[i] = &ItemError{Index: i, Err: err}
+				if opts.ErrorMode == FailFast {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = itemErrs[i]
+						cancel()
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if opts.ErrorMode == FailFast {
+		if firstErr != nil {
+			return results, firstErr
+		}
+		return results, ctx.Err()
+	}
+	return results, errors.Join(append(itemErrs, ctx.Err())...)
+}
+
+func callRecovering[T, R any](ctx context.Context, fn func(context.Context, T) (R, error), item T) (result R, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return fn(ctx, item)
+}
@@ -0,0 +1,233 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// sleepThenDouble is DummyFunction behind a ContextSleeper, the way the
+// exercises use it.
+func sleepThenDouble(sleeper ContextSleeper) func(context.Context, int) (int, error) {
+	return func(ctx context.Context, n int) (int, error) {
+		if err := sleeper.Sleep(ctx, dummyDelay); err != nil {
+			return 0, err
+		}
+		return DummyFunction(n), nil
+	}
+}
+
+func TestParallelMap(t *testing.T) {
+
+	t.Run("keeps the order of the items", func(t *testing.T) {
+		sleeper := &SleeperMock{}
+		got, err := ParallelMap(context.Background(), []int{1, 2, 3}, sleepThenDouble(sleeper), Options{})
+
+		assertNoError(t, err)
+		assertResults(t, got, []int{2, 4, 6})
+	})
+
+	t.Run("calls fn once per item", func(t *testing.T) {
+		sleeper := &SleeperMock{}
+		ParallelMap(context.Background(), []int{1, 2, 3}, sleepThenDouble(sleeper), Options{})
+
+		if sleeper.Calls != 3 {
+			t.Errorf("expected function to run 3 times, but it ran %d times", sleeper.Calls)
+		}
+	})
+
+	t.Run("runs the items at the same time", func(t *testing.T) {
+		items := []int{1, 2, 3}
+		var started sync.WaitGroup
+		started.Add(len(items))
+		allStarted := make(chan struct{})
+		go func() {
+			started.Wait()
+			close(allStarted)
+		}()
+
+		got, err := ParallelMap(context.Background(), items, func(_ context.Context, n int) (int, error) {
+			started.Done()
+			select {
+			case <-allStarted:
+				return DummyFunction(n), nil
+			case <-time.After(time.Second):
+				return 0, errors.New("the other items never started")
+			}
+		}, Options{})
+
+		assertNoError(t, err)
+		assertResults(t, got, []int{2, 4, 6})
+	})
+
+	t.Run("runs at most Workers items at a time", func(t *testing.T) {
+		var running, most atomic.Int64
+		got, err := ParallelMap(context.Background(), []int{1, 2, 3, 4, 5, 6}, func(_ context.Context, n int) (int, error) {
+			now := running.Add(1)
+			defer running.Add(-1)
+			for {
+				seen := most.Load()
+				if now <= seen || most.CompareAndSwap(seen, now) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			return DummyFunction(n), nil
+		}, Options{Workers: 2})
+
+		assertNoError(t, err)
+		assertResults(t, got, []int{2, 4, 6, 8, 10, 12})
+		if most.Load() > 2 {
+			t.Errorf("got %d items running at once want at most 2", most.Load())
+		}
+	})
+
+	t.Run("handles no items", func(t *testing.T) {
+		got, err := ParallelMap(context.Background(), nil, sleepThenDouble(&SleeperMock{}), Options{})
+
+		assertNoError(t, err)
+		if len(got) != 0 {
+			t.Errorf("got %v want no results", got)
+		}
+	})
+
+	t.Run("fails fast by default", func(t *testing.T) {
+		boom := errors.New("boom")
+		sleeper := &SleeperMock{}
+		_, err := ParallelMap(context.Background(), []int{1, 2, 3, 4}, func(ctx context.Context, n int) (int, error) {
+			if n == 2 {
+				return 0, boom
+			}
+			sleeper.Sleep(ctx, dummyDelay)
+			return DummyFunction(n), nil
+		}, Options{Workers: 1})
+
+		assertItemError(t, err, 1, boom)
+		if sleeper.Calls != 1 {
+			t.Errorf("got %d items run after the failure want none, only the first", sleeper.Calls)
+		}
+	})
+
+	t.Run("fail fast cancels the items that are running", func(t *testing.T) {
+		boom := errors.New("boom")
+		_, err := ParallelMap(context.Background(), []int{1, 2, 3}, func(ctx context.Context, n int) (int, error) {
+			if n == 1 {
+				return 0, boom
+			}
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(time.Second):
+				return 0, errors.New("wasn't cancelled")
+			}
+		}, Options{})
+
+		assertItemError(t, err, 0, boom)
+	})
+
+	t.Run("collects every error", func(t *testing.T) {
+		odd := errors.New("odd")
+		got, err := ParallelMap(context.Background(), []int{1, 2, 3, 4}, func(_ context.Context, n int) (int, error) {
+			if n%2 == 1 {
+				return 0, odd
+			}
+			return DummyFunction(n), nil
+		}, Options{ErrorMode: CollectAll})
+
+		assertResults(t, got, []int{0, 4, 0, 8})
+		if !errors.Is(err, odd) {
+			t.Fatalf("got error %v want it to wrap %v", err, odd)
+		}
+		joined, ok := err.(interface{ Unwrap() []error })
+		if !ok {
+			t.Fatalf("got error %T want joined errors", err)
+		}
+		var indexes []int
+		for _, e := range joined.Unwrap() {
+			var itemErr *ItemError
+			if errors.As(e, &itemErr) {
+				indexes = append(indexes, itemErr.Index)
+			}
+		}
+		if !reflect.DeepEqual(indexes, []int{0, 2}) {
+			t.Errorf("got errors for items %v want 0 and 2", indexes)
+		}
+	})
+
+	t.Run("leaves the zero value for a failed item, whatever fn returned", func(t *testing.T) {
+		two := errors.New("two")
+		got, err := ParallelMap(context.Background(), []int{1, 2, 3}, func(_ context.Context, n int) (int, error) {
+			if n == 2 {
+				return 99, two
+			}
+			return DummyFunction(n), nil
+		}, Options{ErrorMode: CollectAll})
+
+		assertResults(t, got, []int{2, 0, 6})
+		assertItemError(t, err, 1, two)
+	})
+
+	t.Run("recovers a panic in one item", func(t *testing.T) {
+		got, err := ParallelMap(context.Background(), []int{1, 2, 3}, func(_ context.Context, n int) (int, error) {
+			if n == 2 {
+				panic("two")
+			}
+			return DummyFunction(n), nil
+		}, Options{ErrorMode: CollectAll})
+
+		assertResults(t, got, []int{2, 0, 6})
+		var panicErr *PanicError
+		if !errors.As(err, &panicErr) {
+			t.Fatalf("got error %v want a PanicError", err)
+		}
+		if panicErr.Value != "two" || len(panicErr.Stack) == 0 {
+			t.Errorf("got panic %v with a %d byte stack want two with a stack", panicErr.Value, len(panicErr.Stack))
+		}
+	})
+
+	t.Run("stops when ctx is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		sleeper := &SleeperMock{}
+		_, err := ParallelMap(ctx, []int{1, 2, 3}, func(ctx context.Context, n int) (int, error) {
+			sleeper.Sleep(ctx, dummyDelay)
+			cancel()
+			return DummyFunction(n), nil
+		}, Options{Workers: 1, ErrorMode: CollectAll})
+
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v want %v", err, context.Canceled)
+		}
+		if sleeper.Calls != 1 {
+			t.Errorf("got %d items run want only the first", sleeper.Calls)
+		}
+	})
+}
+
+func assertResults(t testing.TB, got, want []int) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func assertNoError(t testing.TB, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("didn't expect an error but got one, %v", err)
+	}
+}
+
+func assertItemError(t testing.TB, err error, index int, want error) {
+	t.Helper()
+	var itemErr *ItemError
+	if !errors.As(err, &itemErr) {
+		t.Fatalf("got error %v want an ItemError", err)
+	}
+	if itemErr.Index != index || !errors.Is(err, want) {
+		t.Errorf("got %v want item %d failing with %v", err, index, want)
+	}
+}
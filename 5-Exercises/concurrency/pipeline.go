@@ -0,0 +1,155 @@
+package concurrency
+
+import (
+	"context"
+	"sync"
+)
+
+// Pipeline ties stages connected by channels together so they share one
+// cancellation and report the first error. Build it from a Source, pass the
+// channels through Map, Filter and Batch, and finish it with a Sink, which
+// waits for every stage to stop.
+type Pipeline struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	stages sync.WaitGroup
+}
+
+// NewPipeline starts a pipeline that stops when ctx is cancelled.
+func NewPipeline(ctx context.Context) *Pipeline {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Pipeline{ctx: ctx, cancel: cancel}
+}
+
+// fail stops every stage; only the first error is kept.
+func (p *Pipeline) fail(err error) {
+	p.cancel(err)
+}
+
+// stage runs fn in its own goroutine and closes out when it returns.
+func stage[T any](p *Pipeline, out chan T, fn func()) {
+	p.stages.Add(1)
+	go func() {
+		defer p.stages.Done()
+		defer close(out)
+		fn()
+	}()
+}
+
+// send hands v on unless the pipeline stops first.
+func send[T any](p *Pipeline, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-p.ctx.Done():
+		return false
+	}
+}
+
+// Source sends items down the pipeline one at a time.
+func Source[T any](p *Pipeline, items []T) <-chan T {
+	out := make(chan T)
+	stage(p, out, func() {
+		for _, item := range items {
+			if !send(p, out, item) {
+				return
+			}
+		}
+	})
+	return out
+}
+
+// Map calls fn on what comes in with workers goroutines and sends on the
+// results as they finish, so they can come out in a different order. An
+// error from fn stops the pipeline.
+func Map[T, R any](p *Pipeline, in <-chan T, workers int, fn func(context.Context, T) (R, error)) <-chan R {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	out := make(chan R)
+	stage(p, out, func() {
+		var wg sync.WaitGroup
+		wg.Add(workers)
+		for w := 0; w < workers; w++ {
+			go func() {
+				defer wg.Done()
+				for v := range in {
+					if p.ctx.Err() != nil {
+						return
+					}
+					r, err := fn(p.ctx, v)
+					if err != nil {
+						p.fail(err)
+						return
+					}
+					if !send(p, out, r) {
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	})
+	return out
+}
+
+// Filter only sends on what keep says yes to.
+func Filter[T any](p *Pipeline, in <-chan T, keep func(T) bool) <-chan T {
+	out := make(chan T)
+	stage(p, out, func() {
+		for v := range in {
+			if keep(v) && !send(p, out, v) {
+				return
+			}
+		}
+	})
+	return out
+}
+
+// Batch groups what comes in into slices of size. The last batch is
+// smaller if the input runs out part way through one.
+func Batch[T any](p *Pipeline, in <-chan T, size int) <-chan []T {
+	if size <= 0 {
+		size = 1
+	}
+
+	out := make(chan []T)
+	stage(p, out, func() {
+		batch := make([]T, 0, size)
+		for v := range in {
+			batch = append(batch, v)
+			if len(batch) < size {
+				continue
+			}
+			if !send(p, out, batch) {
+				return
+			}
+			batch = make([]T, 0, size)
+		}
+		if len(batch) > 0 && p.ctx.Err() == nil {
+			send(p, out, batch)
+		}
+	})
+	return out
+}
+
+// Sink calls fn on everything that reaches the end of the pipeline and
+// waits for all the stages to stop. It returns the first error from fn or a
+// Map stage, or the cause of ctx being cancelled.
+func Sink[T any](p *Pipeline, in <-chan T, fn func(context.Context, T) error) error {
+	for v := range in {
+		if p.ctx.Err() != nil {
+			break
+		}
+		if err := fn(p.ctx, v); err != nil {
+			p.fail(err)
+			break
+		}
+	}
+
+	p.stages.Wait()
+	err := context.Cause(p.ctx)
+	p.cancel(nil)
+	return err
+}
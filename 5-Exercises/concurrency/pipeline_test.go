@@ -0,0 +1,185 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// SpySink remembers everything that reaches the end of a pipeline.
+type SpySink[T any] struct {
+	mu  sync.Mutex
+	got []T
+}
+
+func (s *SpySink[T]) Take(_ context.Context, v T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.got = append(s.got, v)
+	return nil
+}
+
+func double(_ context.Context, n int) (int, error) {
+	return DummyFunction(n), nil
+}
+
+func TestPipeline(t *testing.T) {
+
+	t.Run("maps, filters and batches the numbers", func(t *testing.T) {
+		p := NewPipeline(context.Background())
+		doubled := Map(p, Source(p, []int{1, 2, 3, 4, 5, 6, 7}), 3, double)
+		notSix := Filter(p, doubled, func(n int) bool { return n != 6 })
+		sink := &SpySink[[]int]{}
+
+		err := Sink(p, Batch(p, notSix, 4), sink.Take)
+
+		assertNoError(t, err)
+		if len(sink.got) != 2 || len(sink.got[0]) != 4 || len(sink.got[1]) != 2 {
+			t.Fatalf("got batches %v want one of 4 and one of 2", sink.got)
+		}
+		got := slices.Concat(sink.got...)
+		slices.Sort(got)
+		assertResults(t, got, []int{2, 4, 8, 10, 12, 14})
+	})
+
+	t.Run("fans out to the workers", func(t *testing.T) {
+		workers := 3
+		var started sync.WaitGroup
+		started.Add(workers)
+		allStarted := make(chan struct{})
+		go func() {
+			started.Wait()
+			close(allStarted)
+		}()
+
+		p := NewPipeline(context.Background())
+		out := Map(p, Source(p, []int{1, 2, 3}), workers, func(_ context.Context, n int) (int, error) {
+			started.Done()
+			select {
+			case <-allStarted:
+				return DummyFunction(n), nil
+			case <-time.After(time.Second):
+				return 0, errors.New("the other workers never started")
+			}
+		})
+		sink := &SpySink[int]{}
+
+		assertNoError(t, Sink(p, out, sink.Take))
+		slices.Sort(sink.got)
+		assertResults(t, sink.got, []int{2, 4, 6})
+	})
+
+	t.Run("a failing stage stops the pipeline", func(t *testing.T) {
+		boom := errors.New("boom")
+		p := NewPipeline(context.Background())
+		out := Map(p, Source(p, numbers(1000)), 4, func(_ context.Context, n int) (int, error) {
+			if n == 10 {
+				return 0, boom
+			}
+			return n, nil
+		})
+		sink := &SpySink[int]{}
+
+		err := Sink(p, out, sink.Take)
+
+		if !errors.Is(err, boom) {
+			t.Errorf("got error %v want %v", err, boom)
+		}
+		if len(sink.got) == 1000 {
+			t.Error("every number got through after the failure")
+		}
+	})
+
+	t.Run("a failing sink stops the pipeline", func(t *testing.T) {
+		full := errors.New("full")
+		p := NewPipeline(context.Background())
+		taken := 0
+
+		err := Sink(p, Map(p, Source(p, numbers(1000)), 2, double), func(_ context.Context, _ int) error {
+			taken++
+			if taken == 3 {
+				return full
+			}
+			return nil
+		})
+
+		if !errors.Is(err, full) {
+			t.Errorf("got error %v want %v", err, full)
+		}
+		if taken != 3 {
+			t.Errorf("got %d numbers taken want the sink to stop at 3", taken)
+		}
+	})
+
+	t.Run("stops when ctx is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		p := NewPipeline(ctx)
+		taken := 0
+
+		err := Sink(p, Batch(p, Source(p, numbers(1000)), 10), func(_ context.Context, _ []int) error {
+			taken++
+			if taken == 2 {
+				cancel()
+			}
+			return nil
+		})
+
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v want %v", err, context.Canceled)
+		}
+		if taken != 2 {
+			t.Errorf("got %d batches taken want 2", taken)
+		}
+	})
+
+	t.Run("leaves no goroutines behind", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+
+		for _, fail := range []bool{false, true} {
+			p := NewPipeline(context.Background())
+			mapped := Map(p, Source(p, numbers(100)), 8, func(_ context.Context, n int) (int, error) {
+				if fail && n == 50 {
+					return 0, errors.New("boom")
+				}
+				return DummyFunction(n), nil
+			})
+			batches := Batch(p, Filter(p, mapped, func(n int) bool { return n%3 != 0 }), 7)
+			Sink(p, batches, (&SpySink[[]int]{}).Take)
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if after := runtime.NumGoroutine(); after > before {
+			t.Errorf("got %d goroutines after the pipelines stopped want %d", after, before)
+		}
+	})
+}
+
+func TestBatch(t *testing.T) {
+	p := NewPipeline(context.Background())
+	sink := &SpySink[[]int]{}
+
+	err := Sink(p, Batch(p, Source(p, numbers(5)), 2), sink.Take)
+
+	assertNoError(t, err)
+	want := [][]int{{1, 2}, {3, 4}, {5}}
+	if !reflect.DeepEqual(sink.got, want) {
+		t.Errorf("got batches %v want %v", sink.got, want)
+	}
+}
+
+// numbers is 1 to n.
+func numbers(n int) []int {
+	numbers := make([]int, n)
+	for i := range numbers {
+		numbers[i] = i + 1
+	}
+	return numbers
+}